@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/chameleon/internal/docs"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func runDocs(args []string) error {
+	var sf storageFlags
+	flags := newFlagSet("docs")
+	sf.register(flags)
+	output := flags.String("o", "docs.html", "output `file`")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("docs", "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	cfg, st, err := sf.openReadOnly()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+
+	count, err := docs.Generate(st, *output)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Documented %d recordings from %s in %s\n", count, describeStorage(cfg), *output)
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// version is the Chameleon version, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version string
+
+// chameleonVersion returns the version of this build, falling back to the
+// module version for builds made with go install
+func chameleonVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// command is a subcommand of the chameleon binary
+type command struct {
+	name    string
+	args    string // Synopsis of the positional arguments
+	summary string
+	run     func(args []string) error
+}
+
+// Command names referenced outside the command table
+const commandServe = "serve"
+
+// commands lists the subcommands in the order they are shown in the usage
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{commandServe, "[port] [backend]", "Run the proxy (the default when no command is given)", runServe},
+		{"list", "", "List recordings", runList},
+		{"show", "<key>", "Print a recording", runShow},
+		{"delete", "[key...]", "Delete recordings by key or by filter", runDelete},
+		{"prune", "", "Delete old, failed, or stale recordings", runPrune},
+		{"export", "", "Write recordings to a bundle file", runExport},
+		{"import", "<file>", "Add the recordings of a bundle file to storage", runImport},
+		{"docs", "", "Generate HTML API documentation from recordings", runDocs},
+		{"verify", "", "Check that every recording is readable and stored under its current key", runVerify},
+	}
+}
+
+const usageHeader = `Usage: chameleon [command] [flags] [arguments]
+
+Commands:
+`
+
+const usageFooter = `
+Commands that work on recordings use STORAGE_PATH, which may be set in the
+environment, in the config file (-config or $CHAMELEON_CONFIG), or with -storage.
+Run "chameleon <command> -h" for the flags of a command.
+`
+
+// printUsage writes the list of commands to stderr
+func printUsage() {
+	var b strings.Builder
+	b.WriteString(usageHeader)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	b.WriteString(usageFooter)
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+func main() {
+	args := os.Args[1:]
+
+	// Without a known command name the arguments belong to serve, as in
+	// earlier versions that took only [port] [backend]
+	cmd := lookupCommand(commandServe)
+	if len(args) > 0 {
+		if c := lookupCommand(args[0]); c != nil {
+			cmd, args = c, args[1:]
+		} else if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+			printUsage()
+			return
+		}
+	}
+
+	err := cmd.run(args)
+	var usageErr *usageError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		// The flag set has printed the usage of the command
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s\nRun \"chameleon %s -h\" for details.\n",
+			usageErr.err, synopsis(lookupCommand(usageErr.command)), usageErr.command)
+		os.Exit(2)
+	case errors.Is(err, errFlagParse):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// lookupCommand returns the command called name, or nil
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// errFlagParse reports invalid flags, which the flag set has already printed
+var errFlagParse = errors.New("invalid flags")
+
+// usageError is an invalid invocation of a command
+type usageError struct {
+	command string
+	err     error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+// usageErrorf reports an invalid invocation of the named command
+func usageErrorf(command, format string, args ...interface{}) error {
+	return &usageError{command: command, err: fmt.Errorf(format, args...)}
+}
+
+// flagSet wraps flag.FlagSet to tell flag errors apart from the command's own errors
+type flagSet struct {
+	*flag.FlagSet
+}
+
+// Parse parses args, returning flag.ErrHelp for -h and errFlagParse for
+// invalid flags. Unlike flag.FlagSet, flags may follow positional arguments
+// up to a "--"
+func (f flagSet) Parse(args []string) error {
+	var positional []string
+	for {
+		err := f.FlagSet.Parse(args)
+		if err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return errFlagParse
+		}
+		rest := f.FlagSet.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	// Leave the positional arguments in Args without parsing them again
+	return f.FlagSet.Parse(append([]string{"--"}, positional...))
+}
+
+// synopsis returns the usage line of cmd
+func synopsis(cmd *command) string {
+	return strings.TrimSpace(fmt.Sprintf("Usage: chameleon %s [flags] %s", cmd.name, cmd.args))
+}
+
+// newFlagSet returns the flag set of the named command, with a usage message
+// built from its synopsis
+func newFlagSet(name string) flagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		cmd := lookupCommand(name)
+		fmt.Fprintf(out, "%s\n\n%s\n", synopsis(cmd), cmd.summary)
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(out, "\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	return flagSet{flags}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/proxy"
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// storageFlags are the flags of commands that work on recordings
+type storageFlags struct {
+	opts config.LoadOptions
+}
+
+func (f *storageFlags) register(flags flagSet) {
+	flags.StringVar(&f.opts.ConfigFile, "config", "", "YAML or TOML config `file` (default: $CHAMELEON_CONFIG)")
+	flags.StringVar(&f.opts.StoragePath, "storage", "", "recordings `directory` (default: $STORAGE_PATH or ./recordings)")
+}
+
+// open loads the configuration and the storage it points to
+func (f *storageFlags) open() (*config.Config, storage.Store, error) {
+	return f.openWith(storage.Open)
+}
+
+// openReadOnly is like open, for commands that don't modify recordings
+func (f *storageFlags) openReadOnly() (*config.Config, storage.Store, error) {
+	return f.openWith(storage.OpenReadOnly)
+}
+
+// openWith loads the configuration and opens its storage with open
+func (f *storageFlags) openWith(open func(backend, path string) (storage.Store, error)) (*config.Config, storage.Store, error) {
+	cfg, err := config.Load(&f.opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	st, err := open(cfg.Storage, cfg.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	return cfg, st, nil
+}
+
+// describeStorage names the storage a configuration points to, for messages
+func describeStorage(cfg *config.Config) string {
+	switch cfg.Storage {
+	case storage.BackendFile:
+		return cfg.StoragePath
+	case storage.BackendMemory:
+		return "memory"
+	default:
+		return fmt.Sprintf("%s (%s)", cfg.StoragePath, cfg.Storage)
+	}
+}
+
+// filter selects recordings by route pattern, status code, namespace and
+// cassette
+// Recordings carry no tags; a cassette is the named set to group them by
+type filter struct {
+	match     string
+	status    string
+	namespace string
+	cassette  string
+
+	pattern              *route.Pattern
+	minStatus, maxStatus int
+}
+
+func (f *filter) register(flags flagSet) {
+	flags.StringVar(&f.match, "match", "", "only recordings of requests matching a route `pattern`, e.g. \"GET /api/*\"")
+	flags.StringVar(&f.status, "status", "", "only recordings with a status `code` such as 404, or a class such as 5xx")
+	flags.StringVar(&f.namespace, "namespace", "", "only recordings in a `namespace`, such as the name of the route that recorded them")
+	flags.StringVar(&f.cassette, "cassette", "", "only recordings in the cassette with this `name`")
+}
+
+// compile parses the filter flags; command names the command for usage errors
+func (f *filter) compile(command string) error {
+	if f.match != "" {
+		pattern, err := route.Parse(f.match)
+		if err != nil {
+			return usageErrorf(command, "invalid -match: %v", err)
+		}
+		f.pattern = pattern
+	}
+
+	switch {
+	case f.status == "":
+	case len(f.status) == 3 && strings.HasSuffix(strings.ToLower(f.status), "xx"):
+		class, err := strconv.Atoi(f.status[:1])
+		if err != nil || class < 1 || class > 5 {
+			return usageErrorf(command, "invalid -status: %s", f.status)
+		}
+		f.minStatus, f.maxStatus = class*100, class*100+99
+	default:
+		code, err := strconv.Atoi(f.status)
+		if err != nil {
+			return usageErrorf(command, "invalid -status: %s", f.status)
+		}
+		f.minStatus, f.maxStatus = code, code
+	}
+
+	if f.cassette != "" && !storage.ValidCassette(f.cassette) {
+		return usageErrorf(command, "invalid -cassette: %s (must be letters, digits, '.', '_' or '-')", f.cassette)
+	}
+	return nil
+}
+
+// empty reports whether the filter selects every recording
+func (f *filter) empty() bool {
+	return f.match == "" && f.status == "" && f.namespace == "" && f.cassette == ""
+}
+
+// String returns the filter as command line flags, empty if it selects every
+// recording
+func (f *filter) String() string {
+	var flags []string
+	if f.match != "" {
+		flags = append(flags, "-match "+strconv.Quote(f.match))
+	}
+	if f.status != "" {
+		flags = append(flags, "-status "+f.status)
+	}
+	if f.namespace != "" {
+		flags = append(flags, "-namespace "+strconv.Quote(f.namespace))
+	}
+	if f.cassette != "" {
+		flags = append(flags, "-cassette "+f.cassette)
+	}
+	return strings.Join(flags, " ")
+}
+
+// matches reports whether the filter selects the recording s describes
+func (f *filter) matches(s storage.Summary) bool {
+	if f.pattern != nil && !f.pattern.Match(s.Method, s.Path) {
+		return false
+	}
+	if f.status != "" && (s.StatusCode < f.minStatus || s.StatusCode > f.maxStatus) {
+		return false
+	}
+	if f.namespace != "" {
+		// Namespaces nest, e.g. a route namespace within another
+		namespace, _ := storage.SplitKey(s.Key)
+		if namespace != f.namespace && !strings.HasPrefix(namespace, f.namespace+"/") {
+			return false
+		}
+	}
+	if f.cassette != "" {
+		if cassette, _ := storage.SplitCassette(s.Key); cassette != f.cassette {
+			return false
+		}
+	}
+	return true
+}
+
+// query returns the part of the filter stores can answer from their indexes
+// It may select more recordings than the filter; matches has the final say
+func (f *filter) query() storage.Query {
+	q := storage.Query{MinStatus: f.minStatus, MaxStatus: f.maxStatus}
+	if f.pattern != nil {
+		q.Method = f.pattern.Method()
+		// The literal segments of the path, up to the first wildcard, since
+		// the query matches whole segments
+		template := f.pattern.Template()
+		if i := strings.IndexAny(template, "{*"); i >= 0 {
+			template = template[:strings.LastIndex(template[:i], "/")+1]
+		}
+		q.PathPrefix = template
+	}
+	return q
+}
+
+// search returns the summaries of the recordings f selects, sorted by key
+// Stores with indexes are searched; others are read in full
+func search(st storage.Store, f *filter) ([]storage.Summary, error) {
+	var results []storage.Summary
+	if searcher, ok := st.(storage.Searcher); ok {
+		found, err := searcher.Search(f.query())
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range found {
+			if f.matches(s) {
+				results = append(results, s)
+			}
+		}
+		return results, nil
+	}
+
+	err := st.Iterate(func(key string, cached *storage.CachedResponse, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", key, err)
+			return nil
+		}
+		if s := storage.Summarize(key, cached); f.matches(s) {
+			results = append(results, s)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// resolveKey expands an abbreviated key, as printed by list, to the full key
+// of the single recording it identifies
+func resolveKey(st storage.Store, prefix string) (string, error) {
+	if st.Exists(prefix) {
+		return prefix, nil
+	}
+	keys, err := st.List()
+	if err != nil {
+		return "", err
+	}
+
+	var found []string
+	for _, key := range keys {
+		_, hash := storage.SplitKey(key)
+		if strings.HasPrefix(key, prefix) || strings.HasPrefix(hash, prefix) {
+			found = append(found, key)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no recording found for key %s", prefix)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("key %s is ambiguous, it matches %d recordings", prefix, len(found))
+	}
+}
+
+// shortKey abbreviates a storage key for display, keeping its namespace
+func shortKey(key string) string {
+	namespace, hash := storage.SplitKey(key)
+	if len(hash) > 16 {
+		hash = hash[:16]
+	}
+	return storage.Key(namespace, hash)
+}
+
+// describe returns "METHOD uri" for a recording, preferring the recorded
+// request URI over the path
+func describe(cached *storage.CachedResponse) string {
+	uri := cached.Path
+	if cached.Request != nil && cached.Request.URL != "" {
+		uri = cached.Request.URL
+	}
+	return cached.Method + " " + uri
+}
+
+// recordedAt returns when a recording was made, or the zero time for
+// recordings made before requests were stored
+func recordedAt(cached *storage.CachedResponse) time.Time {
+	if cached.Request == nil {
+		return time.Time{}
+	}
+	return cached.Request.Timestamp
+}
+
+func runList(args []string) error {
+	var sf storageFlags
+	var f filter
+	flags := newFlagSet("list")
+	sf.register(flags)
+	f.register(flags)
+	full := flags.Bool("full", false, "print full keys instead of abbreviated ones")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("list", "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if err := f.compile("list"); err != nil {
+		return err
+	}
+
+	_, st, err := sf.openReadOnly()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+
+	summaries, err := search(st, &f)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tMETHOD\tURL\tSTATUS\tRECORDED")
+	for _, s := range summaries {
+		key := s.Key
+		if !*full {
+			key = shortKey(key)
+		}
+		status := strconv.Itoa(s.StatusCode)
+		if s.Responses > 1 {
+			status += fmt.Sprintf(" (+%d)", s.Responses-1)
+		}
+		recorded := "-"
+		if !s.RecordedAt.IsZero() {
+			recorded = s.RecordedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		uri := s.URL
+		if uri == "" {
+			uri = s.Path
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", key, s.Method, uri, status, recorded)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d recordings\n", len(summaries))
+	return nil
+}
+
+func runShow(args []string) error {
+	var sf storageFlags
+	flags := newFlagSet("show")
+	sf.register(flags)
+	body := flags.Bool("body", false, "print only the response body, decoded")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usageErrorf("show", "expected exactly one key")
+	}
+
+	_, st, err := sf.openReadOnly()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+	key, err := resolveKey(st, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	cached, err := st.Load(key)
+	if err != nil {
+		return err
+	}
+
+	if *body {
+		_, err := os.Stdout.Write(cached.Body)
+		return err
+	}
+
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+	fmt.Printf("# %s\n%s\n", key, data)
+	return nil
+}
+
+func runDelete(args []string) error {
+	var sf storageFlags
+	var f filter
+	flags := newFlagSet("delete")
+	sf.register(flags)
+	f.register(flags)
+	dryRun := flags.Bool("dry-run", false, "print what would be deleted without deleting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := f.compile("delete"); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 && f.empty() {
+		return usageErrorf("delete", "give the keys to delete, or select recordings with -match, -status, or -namespace")
+	}
+	if flags.NArg() > 0 && !f.empty() {
+		return usageErrorf("delete", "keys can't be combined with -match, -status, or -namespace")
+	}
+
+	_, st, err := sf.open()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+
+	var keys []string
+	for _, prefix := range flags.Args() {
+		key, err := resolveKey(st, prefix)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	if !f.empty() {
+		summaries, err := search(st, &f)
+		if err != nil {
+			return err
+		}
+		for _, s := range summaries {
+			keys = append(keys, s.Key)
+		}
+	}
+
+	return deleteRecordings(st, keys, *dryRun)
+}
+
+// deleteRecordings deletes keys from st, or only lists them on a dry run
+func deleteRecordings(st storage.Store, keys []string, dryRun bool) error {
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	for _, key := range keys {
+		if !dryRun {
+			if err := st.Delete(key); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s %s\n", verb, key)
+	}
+	fmt.Fprintf(os.Stderr, "%s %d recordings\n", verb, len(keys))
+	return nil
+}
+
+func runPrune(args []string) error {
+	var sf storageFlags
+	var f filter
+	flags := newFlagSet("prune")
+	sf.register(flags)
+	f.register(flags)
+	olderThan := flags.Duration("older-than", 0, "only recordings made longer than `duration` ago, e.g. 720h")
+	stale := flags.Bool("stale", false, "only recordings the current matching configuration no longer finds")
+	dryRun := flags.Bool("dry-run", false, "print what would be deleted without deleting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("prune", "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if err := f.compile("prune"); err != nil {
+		return err
+	}
+	if *olderThan <= 0 && f.status == "" && !*stale {
+		return usageErrorf("prune", "select recordings with -older-than, -status, or -stale")
+	}
+
+	cfg, st, err := sf.open()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+	keyer, err := newKeyer(cfg, st)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-*olderThan)
+	var keys []string
+	undated := 0
+	err = st.Iterate(func(key string, cached *storage.CachedResponse, err error) error {
+		if err != nil || !f.matches(storage.Summarize(key, cached)) {
+			return nil
+		}
+		if *olderThan > 0 {
+			t := recordedAt(cached)
+			if t.IsZero() {
+				undated++
+				return nil
+			}
+			if t.After(cutoff) {
+				return nil
+			}
+		}
+		if *stale {
+			current, err := keyer.key(key, cached)
+			if err != nil || current == key {
+				return nil
+			}
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if undated > 0 {
+		fmt.Fprintf(os.Stderr, "Kept %d recordings without a timestamp (made before requests were stored)\n", undated)
+	}
+	return deleteRecordings(st, keys, *dryRun)
+}
+
+func runVerify(args []string) error {
+	var sf storageFlags
+	flags := newFlagSet("verify")
+	sf.register(flags)
+	fix := flags.Bool("fix", false, "move recordings stored under an outdated key to their current key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("verify", "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	open := sf.openReadOnly
+	if *fix {
+		open = sf.open
+	}
+	cfg, st, err := open()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+	keyer, err := newKeyer(cfg, st)
+	if err != nil {
+		return err
+	}
+
+	checked, problems, unverifiable := 0, 0, 0
+	report := func(key, format string, args ...interface{}) {
+		problems++
+		fmt.Printf("%s: %s\n", shortKey(key), fmt.Sprintf(format, args...))
+	}
+
+	type move struct {
+		from, to string
+		cached   *storage.CachedResponse
+	}
+	var moves []move
+
+	err = st.Iterate(func(key string, cached *storage.CachedResponse, err error) error {
+		checked++
+		if err != nil {
+			report(key, "%v", err)
+			return nil
+		}
+		if cached.Method == "" || cached.Path == "" {
+			report(key, "recording has no method or path")
+			return nil
+		}
+		for i, resp := range cached.Responses() {
+			if resp.StatusCode < 100 || resp.StatusCode > 599 {
+				report(key, "%s: response %d has invalid status %d", describe(cached), i+1, resp.StatusCode)
+			}
+		}
+
+		if cached.Request == nil {
+			unverifiable++
+			return nil
+		}
+		current, err := keyer.key(key, cached)
+		if err != nil {
+			report(key, "%s: failed to compute key: %v", describe(cached), err)
+			return nil
+		}
+		if current == key {
+			return nil
+		}
+		if !*fix {
+			report(key, "%s: stored under an outdated key, the current configuration looks for %s", describe(cached), shortKey(current))
+			return nil
+		}
+		moves = append(moves, move{from: key, to: current, cached: cached})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Moving during the iteration could make it visit moved recordings again
+	for _, m := range moves {
+		if st.Exists(m.to) {
+			report(m.from, "%s: can't move to %s, a recording already exists there", describe(m.cached), shortKey(m.to))
+			continue
+		}
+		if err := st.Save(m.to, m.cached); err != nil {
+			return err
+		}
+		if err := st.Delete(m.from); err != nil {
+			return err
+		}
+		fmt.Printf("%s: moved to %s\n", shortKey(m.from), shortKey(m.to))
+	}
+
+	fmt.Fprintf(os.Stderr, "Checked %d recordings", checked)
+	if unverifiable > 0 {
+		fmt.Fprintf(os.Stderr, " (%d without request data, their keys can't be checked)", unverifiable)
+	}
+	fmt.Fprintln(os.Stderr)
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	return nil
+}
+
+// keyer computes the key the proxy would store a recorded request under
+type keyer struct {
+	handler *proxy.Handler
+}
+
+// newKeyer returns a keyer for the matching configuration of cfg
+func newKeyer(cfg *config.Config, st storage.Store) (*keyer, error) {
+	handler, err := proxy.New(cfg, st, log.New(io.Discard, "", 0))
+	if err != nil {
+		return nil, err
+	}
+	return &keyer{handler: handler}, nil
+}
+
+// key returns the current key of the recording stored under key
+// Recordings stay in their cassette. Recordings made before the request host
+// was stored keep their namespace, since host routes can't be matched again
+// without it
+func (k *keyer) key(key string, cached *storage.CachedResponse) (string, error) {
+	req, body, err := cached.HTTPRequest()
+	if err != nil {
+		return "", err
+	}
+	current, err := k.handler.Key(req, body)
+	if err != nil {
+		return "", err
+	}
+	cassette, key := storage.SplitCassette(key)
+	if req.Host == "" {
+		namespace, _ := storage.SplitKey(key)
+		_, hash := storage.SplitKey(current)
+		current = storage.Key(namespace, hash)
+	}
+	return storage.CassetteKey(cassette, current), nil
+}
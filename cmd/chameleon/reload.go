@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/proxy"
+)
+
+// watchInterval is how often the config and rules files are checked for changes
+const watchInterval = time.Second
+
+// reloader reloads the configuration of a running proxy when its files
+// change or on SIGHUP
+type reloader struct {
+	opts    *config.LoadOptions
+	handler *proxy.Handler
+	logger  *log.Logger
+
+	mu  sync.Mutex
+	cfg *config.Config // Configuration in effect
+}
+
+// reload loads the configuration again and switches the handler to it
+// Invalid configurations are logged and leave the current one in effect
+func (rl *reloader) reload(reason string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	next, err := config.Load(rl.opts)
+	if err != nil {
+		rl.logger.Printf("[RELOAD] %s, keeping the current configuration: %v", reason, err)
+		return
+	}
+
+	// The listener and storage stay as they are until a restart
+	prev := rl.cfg
+	if next.Port != prev.Port {
+		rl.logger.Printf("[RELOAD] Port changed to %d, restart to apply it (still listening on :%d)", next.Port, prev.Port)
+		next.Port = prev.Port
+	}
+	if next.StoragePath != prev.StoragePath || next.Storage != prev.Storage {
+		rl.logger.Printf("[RELOAD] Storage changed to %s (%s), restart to apply it (still using %s (%s))",
+			next.StoragePath, next.Storage, prev.StoragePath, prev.Storage)
+		next.StoragePath, next.Storage = prev.StoragePath, prev.Storage
+	}
+
+	changes := config.Changes(prev, next)
+	if len(changes) == 0 {
+		rl.logger.Printf("[RELOAD] %s, configuration unchanged", reason)
+		return
+	}
+	if err := rl.handler.Reload(next); err != nil {
+		rl.logger.Printf("[RELOAD] %s, keeping the current configuration: %v", reason, err)
+		return
+	}
+	rl.cfg = next
+	rl.logger.Printf("[RELOAD] %s, configuration reloaded: %s", reason, strings.Join(changes, "; "))
+}
+
+// watch reloads the configuration whenever the config file or the rules file
+// changes, until stop is closed
+// Files are polled, which works the same on every platform and with editors
+// that replace files instead of writing them in place
+func (rl *reloader) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	seen := rl.fileStates()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := rl.fileStates()
+		for path, state := range current {
+			if seen[path] != state {
+				rl.reload(path + " changed")
+				// Pick up a rules file the new configuration refers to
+				current = rl.fileStates()
+				break
+			}
+		}
+		seen = current
+	}
+}
+
+// fileState identifies a version of a watched file
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// fileStates returns the state of the files the configuration was loaded from
+func (rl *reloader) fileStates() map[string]fileState {
+	rl.mu.Lock()
+	paths := []string{rl.cfg.ConfigFile, rl.cfg.RulesFile}
+	rl.mu.Unlock()
+
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		var state fileState
+		if info, err := os.Stat(path); err == nil {
+			state = fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+		}
+		states[path] = state
+	}
+	return states
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/proxy"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish after a shutdown signal
+const shutdownTimeout = 15 * time.Second
+
+// runServe runs the proxy until it receives SIGINT or SIGTERM
+// SIGHUP and changes to the config or rules file reload the configuration
+func runServe(args []string) error {
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	opts, err := parseServeArgs(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	st, err := storage.Open(cfg.Storage, cfg.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	handler, err := proxy.New(cfg, st, logger)
+	if err != nil {
+		storage.Close(st)
+		return fmt.Errorf("failed to create proxy handler: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Don't let requests held open by injected faults delay the shutdown
+	server.RegisterOnShutdown(handler.Stop)
+
+	logger.Printf("🦎 Chameleon listening on :%d | Mode: %s | Backend: %s | Storage: %s",
+		cfg.Port, cfg.Mode, cfg.BackendURL, describeStorage(cfg))
+	if cfg.Cassette != "" {
+		logger.Printf("Using cassette %s", cfg.Cassette)
+	}
+	if cfg.ConfigFile != "" {
+		logger.Printf("Loaded configuration from %s (reloaded on change or SIGHUP)", cfg.ConfigFile)
+	}
+	for _, rt := range cfg.Routes {
+		match := rt.Match
+		if rt.Host != "" {
+			match = fmt.Sprintf("%s (host %s)", match, rt.Host)
+		}
+		logger.Printf("Route %s: %s -> %s", rt.Name, match, rt.Backend)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	rl := &reloader{opts: opts, handler: handler, logger: logger, cfg: cfg}
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go rl.watch(stopWatching)
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+wait:
+	for {
+		select {
+		case err := <-serverErr:
+			// The server stopped on its own, e.g. because the port is taken
+			handler.Stop()
+			handler.Wait()
+			storage.Close(st)
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return fmt.Errorf("server failed: %w", err)
+		case sig := <-reloads:
+			rl.reload("Received " + sig.String())
+		case sig := <-signals:
+			logger.Printf("Received %s, shutting down (send again to force exit)...", sig)
+			break wait
+		}
+	}
+
+	// A second signal aborts the graceful shutdown
+	go func() {
+		sig := <-signals
+		logger.Printf("Received %s again, exiting immediately", sig)
+		os.Exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting new connections and wait for active requests to complete
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Printf("[ERROR] Graceful shutdown incomplete: %v", err)
+	}
+
+	// Make sure no recording is left half-written on disk
+	handler.Wait()
+	if err := storage.Close(st); err != nil {
+		logger.Printf("[ERROR] Failed to close storage: %v", err)
+	}
+
+	logger.Printf("Shutdown complete")
+	return nil
+}
+
+// parseServeArgs converts the flags of the serve command into load options
+// The positional [port] [backend] arguments of earlier versions still work
+func parseServeArgs(args []string) (*config.LoadOptions, error) {
+	opts := &config.LoadOptions{}
+	flags := newFlagSet(commandServe)
+	flags.StringVar(&opts.ConfigFile, "config", "", "YAML or TOML config `file` (default: $CHAMELEON_CONFIG)")
+	flags.StringVar(&opts.Mode, "mode", "", "operation `mode` (default: $MODE or record)")
+	flags.StringVar(&opts.StoragePath, "storage", "", "recordings `directory` (default: $STORAGE_PATH or ./recordings)")
+	flags.StringVar(&opts.Cassette, "cassette", "", "`name` of the cassette to record to and replay from (default: $CASSETTE, or the default recordings)")
+	port := flags.Int("port", 0, "`port` to listen on (default: $PORT or 3000)")
+	backend := flags.String("backend", "", "backend `URL` or hostname (default: $BACKEND_URL or http://localhost:8080)")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	portSet := false
+	flags.Visit(func(f *flag.Flag) {
+		portSet = portSet || f.Name == "port"
+	})
+
+	args = flags.Args()
+	if len(args) > 2 {
+		return nil, usageErrorf(commandServe, "too many arguments")
+	}
+	if len(args) > 0 {
+		p, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, usageErrorf(commandServe, "invalid port: %s", args[0])
+		}
+		*port, portSet = p, true
+	}
+	if len(args) > 1 {
+		*backend = args[1]
+	}
+
+	if portSet {
+		if *port < 1 || *port > 65535 {
+			return nil, usageErrorf(commandServe, "invalid port: %d (must be between 1 and 65535)", *port)
+		}
+		opts.Port = port
+	}
+	if *backend != "" {
+		opts.Backend = backend
+	}
+
+	return opts, nil
+}
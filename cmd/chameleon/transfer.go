@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/har"
+	"github.com/yourusername/chameleon/internal/pack"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// bundle is the file format of export and import: recordings with their keys
+type bundle struct {
+	Recordings []bundleEntry `json:"recordings"`
+}
+
+// bundleEntry is a recording in a bundle
+type bundleEntry struct {
+	Key       string                  `json:"key"`
+	Recording *storage.CachedResponse `json:"recording"`
+}
+
+// File formats of export and import
+const (
+	formatBundle = "bundle" // Chameleon's own format, lossless
+	formatHAR    = "har"    // HAR 1.2, as exported by browsers' developer tools
+	formatPack   = "pack"   // Compressed archive with a manifest, see internal/pack
+)
+
+// formatUsage describes the -format flag of export and import
+const formatUsage = "file `format`: bundle, har, or pack (default har for .har files, pack for .tar.gz and .tgz files, bundle otherwise)"
+
+// fileFormat returns the format to use for the named file: the one given with
+// -format, or the one its extension suggests
+func fileFormat(command, format, name string) (string, error) {
+	switch format {
+	case "":
+		name = strings.ToLower(name)
+		switch {
+		case strings.HasSuffix(name, ".har"):
+			return formatHAR, nil
+		case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+			return formatPack, nil
+		}
+		return formatBundle, nil
+	case formatBundle, formatHAR, formatPack:
+		return format, nil
+	}
+	return "", usageErrorf(command, "invalid -format: %s (must be bundle, har, or pack)", format)
+}
+
+// Import conflict policies, applied when a key is already recorded
+const (
+	conflictSkip      = "skip"      // Keep the existing recording
+	conflictOverwrite = "overwrite" // Replace it with the imported one
+	conflictKeepBoth  = "keep-both" // Keep it and import a copy into the imported cassette
+)
+
+func runExport(args []string) error {
+	var sf storageFlags
+	var f filter
+	flags := newFlagSet("export")
+	sf.register(flags)
+	f.register(flags)
+	output := flags.String("o", "-", "output `file`, - for stdout")
+	formatFlag := flags.String("format", "", formatUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("export", "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if err := f.compile("export"); err != nil {
+		return err
+	}
+	format, err := fileFormat("export", *formatFlag, *output)
+	if err != nil {
+		return err
+	}
+
+	cfg, st, err := sf.openReadOnly()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+
+	summaries, err := search(st, &f)
+	if err != nil {
+		return err
+	}
+	var b bundle
+	for _, s := range summaries {
+		cached, err := st.Load(s.Key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", s.Key, err)
+			continue
+		}
+		b.Recordings = append(b.Recordings, bundleEntry{Key: s.Key, Recording: cached})
+	}
+
+	var data []byte
+	switch format {
+	case formatHAR:
+		recordings := make([]*storage.CachedResponse, len(b.Recordings))
+		for i, entry := range b.Recordings {
+			recordings[i] = entry.Recording
+		}
+		file := har.Export(recordings, har.Creator{Name: "chameleon", Version: chameleonVersion()}, time.Now())
+		data, err = json.MarshalIndent(file, "", "  ")
+		data = append(data, '\n')
+	case formatPack:
+		entries := make([]pack.Entry, len(b.Recordings))
+		for i, entry := range b.Recordings {
+			entries[i] = pack.Entry{Key: entry.Key, Recording: entry.Recording}
+		}
+		manifest := pack.Manifest{
+			Chameleon:    chameleonVersion(),
+			CreatedAt:    time.Now().UTC(),
+			HashStrategy: cfg.Hash.Strategy,
+			Filter:       f.String(),
+		}
+		var buf bytes.Buffer
+		err = pack.Write(&buf, manifest, entries)
+		data = buf.Bytes()
+	default:
+		data, err = json.MarshalIndent(b, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	if err := writeOutput(*output, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d recordings\n", len(b.Recordings))
+	return nil
+}
+
+func runImport(args []string) error {
+	var sf storageFlags
+	flags := newFlagSet("import")
+	sf.register(flags)
+	conflict := flags.String("conflict", conflictSkip, "what to do with keys that are already recorded: skip, overwrite, or keep-both")
+	dryRun := flags.Bool("dry-run", false, "print what would be imported without importing it")
+	formatFlag := flags.String("format", "", formatUsage)
+	cassette := flags.String("cassette", "", "import into the cassette with this `name` instead of the cassettes the recordings come from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usageErrorf("import", "expected exactly one file (- for stdin)")
+	}
+	switch *conflict {
+	case conflictSkip, conflictOverwrite, conflictKeepBoth:
+	default:
+		return usageErrorf("import", "invalid -conflict: %s (must be skip, overwrite, or keep-both)", *conflict)
+	}
+	if *cassette != "" && !storage.ValidCassette(*cassette) {
+		return usageErrorf("import", "invalid -cassette: %s (must be letters, digits, '.', '_' or '-')", *cassette)
+	}
+	name := flags.Arg(0)
+	format, err := fileFormat("import", *formatFlag, name)
+	if err != nil {
+		return err
+	}
+
+	data, err := readInput(name)
+	if err != nil {
+		return err
+	}
+	var b bundle
+	var file har.File
+	var manifest *pack.Manifest
+	switch format {
+	case formatHAR:
+		if err = json.Unmarshal(data, &file); err == nil && file.Log.Version == "" {
+			err = fmt.Errorf("no HAR log found")
+		}
+	case formatPack:
+		var entries []pack.Entry
+		manifest, entries, err = pack.Read(bytes.NewReader(data))
+		for _, entry := range entries {
+			b.Recordings = append(b.Recordings, bundleEntry{Key: entry.Key, Recording: entry.Recording})
+		}
+	default:
+		err = json.Unmarshal(data, &b)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s %s: %w", format, name, err)
+	}
+
+	cfg, st, err := sf.open()
+	if err != nil {
+		return err
+	}
+	defer storage.Close(st)
+
+	if format == formatHAR {
+		if b, err = harBundle(&file, cfg, st); err != nil {
+			return err
+		}
+	}
+	if manifest != nil {
+		fmt.Fprintf(os.Stderr, "Pack of %d recordings (%d responses) made by chameleon %s on %s\n",
+			manifest.Recordings, manifest.Responses, manifest.Chameleon, manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		if manifest.HashStrategy != cfg.Hash.Strategy {
+			fmt.Fprintf(os.Stderr, "Warning: the pack was recorded with hash strategy %s, the current one is %s; run chameleon verify after importing\n",
+				manifest.HashStrategy, cfg.Hash.Strategy)
+		}
+	}
+
+	imported, skipped := 0, 0
+	for i, entry := range b.Recordings {
+		if !validKey(entry.Key) || entry.Recording == nil {
+			return fmt.Errorf("invalid bundle entry %d: missing or invalid key or recording", i)
+		}
+		key, note := entry.Key, ""
+		if *cassette != "" {
+			_, within := storage.SplitCassette(key)
+			key = storage.CassetteKey(*cassette, within)
+		}
+		if st.Exists(key) {
+			switch *conflict {
+			case conflictSkip:
+				skipped++
+				fmt.Printf("Skipped %s (already recorded)\n", key)
+				continue
+			case conflictKeepBoth:
+				key, note = freeKey(st, key), " (already recorded)"
+			}
+		}
+		if !*dryRun {
+			if err := st.Save(key, entry.Recording); err != nil {
+				return err
+			}
+		}
+		imported++
+		if key != entry.Key {
+			fmt.Printf("Imported %s as %s%s\n", entry.Key, key, note)
+		} else {
+			fmt.Printf("Imported %s\n", key)
+		}
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d recordings, skipped %d\n", verb, imported, skipped)
+	return nil
+}
+
+// harBundle converts the entries of a HAR file into recordings, keyed the way
+// the proxy would key their requests under cfg
+// Entries that can't be replayed, such as requests that got no response, are
+// skipped with a warning. Entries with the same key become a sequence when
+// sequence recording is enabled; otherwise the last one wins
+func harBundle(file *har.File, cfg *config.Config, st storage.Store) (bundle, error) {
+	keyer, err := newKeyer(cfg, st)
+	if err != nil {
+		return bundle{}, err
+	}
+
+	var b bundle
+	index := make(map[string]int) // Key -> position in b.Recordings
+	for i := range file.Log.Entries {
+		entry := &file.Log.Entries[i]
+		cached, err := entry.Recording()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping entry %d (%s %s): %v\n", i, entry.Request.Method, entry.Request.URL, err)
+			continue
+		}
+		key, err := keyer.key("", cached)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping entry %d (%s %s): %v\n", i, entry.Request.Method, entry.Request.URL, err)
+			continue
+		}
+		// Store secret headers the way the proxy records them
+		cached.Request.Headers = keyer.handler.RedactHeaders(http.Header(cached.Request.Headers))
+
+		j, seen := index[key]
+		switch {
+		case !seen:
+			index[key] = len(b.Recordings)
+			b.Recordings = append(b.Recordings, bundleEntry{Key: key, Recording: cached})
+		case cfg.Sequence.Record:
+			b.Recordings[j].Recording.Append(cached.Response)
+		default:
+			b.Recordings[j].Recording = cached
+		}
+	}
+	return b, nil
+}
+
+// freeKey returns the key of a copy of key in a cassette of its own, the first
+// of imported, imported-2... that doesn't record it yet, so replay can select
+// the copy like any other cassette
+// Keys of a cassette get copies in <cassette>.imported, <cassette>.imported-2...
+func freeKey(st storage.Store, key string) string {
+	cassette, within := storage.SplitCassette(key)
+	base := "imported"
+	if cassette != "" {
+		base = cassette + ".imported"
+	}
+	name := base
+	for n := 2; st.Exists(storage.CassetteKey(name, within)); n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	return storage.CassetteKey(name, within)
+}
+
+// validKey reports whether key is safe to store: a hash, optionally preceded
+// by namespaces, that can't escape the storage directory
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `\:`) {
+			return false
+		}
+	}
+	return true
+}
+
+// readInput reads the named file, or stdin for -
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// writeOutput writes data to the named file, or stdout for -
+func writeOutput(name string, data []byte) error {
+	if name == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
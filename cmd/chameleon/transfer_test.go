@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func TestFreeKey(t *testing.T) {
+	st := storage.NewMemoryStore()
+	for _, key := range []string{"cassettes/imported/auth/0a1b", "cassettes/checkout.imported/0a1b"} {
+		if err := st.Save(key, &storage.CachedResponse{}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"0a1b", "cassettes/imported/0a1b"},
+		{"auth/0a1b", "cassettes/imported-2/auth/0a1b"},
+		{"cassettes/checkout/0a1b", "cassettes/checkout.imported-2/0a1b"},
+		{"cassettes/checkout/auth/0a1b", "cassettes/checkout.imported/auth/0a1b"},
+	}
+	for _, tt := range tests {
+		if got := freeKey(st, tt.key); got != tt.want {
+			t.Errorf("freeKey(%s) = %s, want %s", tt.key, got, tt.want)
+		}
+		// The copy replays under its cassette like the original did under its own
+		_, original := storage.SplitCassette(tt.key)
+		if _, within := storage.SplitCassette(tt.want); within != original {
+			t.Errorf("freeKey(%s) keys the copy as %s within its cassette, want %s", tt.key, within, original)
+		}
+	}
+}
+
+func TestFilterCassette(t *testing.T) {
+	f := filter{cassette: "checkout"}
+	if err := f.compile("list"); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"cassettes/checkout/0a1b", true},
+		{"cassettes/checkout/auth/0a1b", true},
+		{"cassettes/checkout.imported/0a1b", false},
+		{"checkout/0a1b", false},
+		{"0a1b", false},
+	}
+	for _, tt := range tests {
+		if got := f.matches(storage.Summary{Key: tt.key}); got != tt.want {
+			t.Errorf("matches(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	invalid := filter{cassette: "../x"}
+	if err := invalid.compile("list"); err == nil {
+		t.Errorf("compile accepted -cassette %s", invalid.cassette)
+	}
+}
@@ -1,3 +1,11 @@
 module github.com/yourusername/chameleon
 
 go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	go.etcd.io/bbolt v1.3.10
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.10.0 // indirect
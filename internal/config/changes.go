@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Changes describes how next differs from prev, one entry per changed
+// setting, named as in the config file, e.g. "mode: record -> replay"
+func Changes(prev, next *Config) []string {
+	var changes []string
+	value := func(name string, a, b interface{}) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, display(a), display(b)))
+		}
+	}
+	section := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, name+" changed")
+		}
+	}
+	rules := func(name string, a, b interface{}) {
+		if reflect.DeepEqual(a, b) {
+			return
+		}
+		before, after := reflect.ValueOf(a).Len(), reflect.ValueOf(b).Len()
+		if before == after {
+			changes = append(changes, fmt.Sprintf("%s changed (%d rules)", name, after))
+		} else {
+			changes = append(changes, fmt.Sprintf("%s: %d -> %d rules", name, before, after))
+		}
+	}
+
+	value("mode", prev.Mode, next.Mode)
+	value("backend", prev.BackendURL, next.BackendURL)
+	rules("routes", prev.Routes, next.Routes)
+	value("port", prev.Port, next.Port)
+	value("storage_path", prev.StoragePath, next.StoragePath)
+	value("storage_backend", prev.Storage, next.Storage)
+	value("cassette", prev.Cassette, next.Cassette)
+	section("hash", prev.Hash, next.Hash)
+	section("fuzzy", prev.Fuzzy, next.Fuzzy)
+	section("sequence", prev.Sequence, next.Sequence)
+	section("stream", prev.Stream, next.Stream)
+	section("websocket", prev.WebSocket, next.WebSocket)
+	value("latency.delay", prev.Latency.Delay, next.Latency.Delay)
+	rules("latency.rules", prev.Latency.Rules, next.Latency.Rules)
+	rules("faults", prev.Faults, next.Faults)
+	value("rules_file", prev.RulesFile, next.RulesFile)
+	rules("transforms", prev.Transforms, next.Transforms)
+	rules("rewrites", prev.Rewrites, next.Rewrites)
+	return changes
+}
+
+// display formats a setting for Changes, showing unset strings as (none)
+func display(v interface{}) interface{} {
+	if s, ok := v.(string); ok && s == "" {
+		return "(none)"
+	}
+	return v
+}
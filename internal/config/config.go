@@ -3,8 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/storage"
 )
 
 // Mode represents the operation mode of the proxy
@@ -14,39 +18,342 @@ const (
 	ModeRecord      Mode = "record"
 	ModeReplay      Mode = "replay"
 	ModePassthrough Mode = "passthrough"
+	// ModeRecordMissing replays recorded responses and records the ones that are missing
+	ModeRecordMissing Mode = "record-missing"
+	// ModeReplayPassthrough replays recorded responses and proxies the ones that are
+	// missing without recording them
+	ModeReplayPassthrough Mode = "replay-passthrough"
 )
 
+// Valid reports whether m is a known mode
+func (m Mode) Valid() bool {
+	switch m {
+	case ModeRecord, ModeReplay, ModePassthrough, ModeRecordMissing, ModeReplayPassthrough:
+		return true
+	}
+	return false
+}
+
+// Records reports whether the mode saves backend responses to storage
+func (m Mode) Records() bool {
+	return m == ModeRecord || m == ModeRecordMissing
+}
+
 // Config holds the application configuration
+// Field tags give the keys of the YAML and TOML config file
 type Config struct {
-	Mode        Mode
-	BackendURL  string
-	Port        int
-	StoragePath string
+	ConfigFile  string          `yaml:"-" toml:"-"` // Config file the configuration was loaded from, if any
+	Mode        Mode            `yaml:"mode" toml:"mode"`
+	BackendURL  string          `yaml:"backend" toml:"backend"` // Backend of requests matched by no route
+	Routes      []BackendRoute  `yaml:"routes" toml:"routes"`   // Per-route backends, first match wins
+	Port        int             `yaml:"port" toml:"port"`
+	StoragePath string          `yaml:"storage_path" toml:"storage_path"`
+	Storage     string          `yaml:"storage_backend" toml:"storage_backend"` // Storage backend: file, memory, archive, or bolt
+	Cassette    string          `yaml:"cassette" toml:"cassette"`               // Named set of recordings to use, empty for the default set
+	Hash        HashConfig      `yaml:"hash" toml:"hash"`
+	Fuzzy       FuzzyConfig     `yaml:"fuzzy" toml:"fuzzy"`
+	Sequence    SequenceConfig  `yaml:"sequence" toml:"sequence"`
+	Stream      StreamConfig    `yaml:"stream" toml:"stream"`
+	WebSocket   WebSocketConfig `yaml:"websocket" toml:"websocket"`
+	Latency     LatencyConfig   `yaml:"latency" toml:"latency"`
+	Faults      []FaultRule     `yaml:"faults" toml:"faults"`
+	RulesFile   string          `yaml:"rules_file" toml:"rules_file"` // JSON file holding routes, transforms and rewrites
+	Transforms  []TransformRule `yaml:"transforms" toml:"transforms"` // Applied to replayed responses, in order
+	Rewrites    []RewriteRule   `yaml:"rewrites" toml:"rewrites"`     // Applied to requests forwarded to the backend, in order
+}
+
+// Fault actions
+const (
+	FaultStatus   = "status"   // Answer with a status code instead of the real response
+	FaultDrop     = "drop"     // Close the connection without answering
+	FaultTruncate = "truncate" // Cut the connection partway through the response body
+	FaultHang     = "hang"     // Never answer, until the client gives up
+)
+
+// FaultRule injects a failure into requests matching a route pattern
+type FaultRule struct {
+	Match       string  `yaml:"match" toml:"match"`             // Route pattern such as "POST /api/checkout"
+	Action      string  `yaml:"action" toml:"action"`           // status, drop, truncate, or hang
+	Status      int     `yaml:"status" toml:"status"`           // Status code answered by the status action
+	Bytes       int     `yaml:"bytes" toml:"bytes"`             // Body bytes the truncate action lets through before cutting the connection
+	Probability float64 `yaml:"probability" toml:"probability"` // Chance (0-1) that a matching request is affected
+}
+
+// Latency modes
+const (
+	LatencyOff      = "off"      // Replay immediately
+	LatencyRecorded = "recorded" // Wait as long as the backend took when the response was recorded
+	LatencyFixed    = "fixed"    // Wait a fixed time
+	LatencyRandom   = "random"   // Wait a random time between Min and Max
+)
+
+// LatencyConfig controls how long replayed responses are held back
+type LatencyConfig struct {
+	Delay Delay         `yaml:"delay" toml:"delay"` // Applies to requests not covered by Rules
+	Rules []LatencyRule `yaml:"rules" toml:"rules"` // Per-route delays, first match wins
+}
+
+// Delay describes the latency simulated for a replayed response
+// In the config file it is written in the syntax accepted by ParseDelay
+type Delay struct {
+	Mode  string        // off, recorded, fixed, or random
+	Fixed time.Duration // Delay of the fixed mode
+	Min   time.Duration // Bounds of the random mode
+	Max   time.Duration
+}
+
+// String formats the delay in the syntax accepted by ParseDelay
+func (d Delay) String() string {
+	switch d.Mode {
+	case LatencyFixed:
+		return fmt.Sprintf("%s:%s", d.Mode, d.Fixed)
+	case LatencyRandom:
+		return fmt.Sprintf("%s:%s-%s", d.Mode, d.Min, d.Max)
+	default:
+		return d.Mode
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for Delay
+func (d *Delay) UnmarshalText(text []byte) error {
+	delay, err := ParseDelay(string(text))
+	if err != nil {
+		return err
+	}
+	*d = delay
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler for Delay
+func (d Delay) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// LatencyRule selects the delay for requests matching a route pattern
+type LatencyRule struct {
+	Match string `yaml:"match" toml:"match"` // Route pattern such as "GET /api/search"
+	Delay Delay  `yaml:"delay" toml:"delay"`
+}
+
+// ParseDelay parses a delay: "off", "recorded", "fixed:200ms", or "random:100ms-800ms"
+// A bare duration such as "200ms" is short for a fixed delay
+func ParseDelay(value string) (Delay, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	mode, args, _ := strings.Cut(value, ":")
+
+	switch mode {
+	case LatencyOff, LatencyRecorded:
+		if args != "" {
+			return Delay{}, fmt.Errorf("invalid delay %q: %s takes no arguments", value, mode)
+		}
+		return Delay{Mode: mode}, nil
+
+	case LatencyFixed:
+		fixed, err := time.ParseDuration(args)
+		if err != nil {
+			return Delay{}, fmt.Errorf("invalid delay %q: %w", value, err)
+		}
+		return Delay{Mode: mode, Fixed: fixed}, nil
+
+	case LatencyRandom:
+		lo, hi, ok := strings.Cut(args, "-")
+		if !ok {
+			return Delay{}, fmt.Errorf("invalid delay %q: expected random:MIN-MAX", value)
+		}
+		shortest, err := time.ParseDuration(lo)
+		if err != nil {
+			return Delay{}, fmt.Errorf("invalid delay %q: %w", value, err)
+		}
+		longest, err := time.ParseDuration(hi)
+		if err != nil {
+			return Delay{}, fmt.Errorf("invalid delay %q: %w", value, err)
+		}
+		return Delay{Mode: mode, Min: shortest, Max: longest}, nil
+	}
+
+	if fixed, err := time.ParseDuration(value); err == nil {
+		return Delay{Mode: LatencyFixed, Fixed: fixed}, nil
+	}
+	return Delay{}, fmt.Errorf("invalid delay %q: must be off, recorded, fixed:DURATION, or random:MIN-MAX", value)
+}
+
+// validate checks the delay's mode and durations
+func (d Delay) validate() error {
+	switch d.Mode {
+	case LatencyOff, LatencyRecorded:
+	case LatencyFixed:
+		if d.Fixed < 0 {
+			return fmt.Errorf("%s: delay cannot be negative", d)
+		}
+	case LatencyRandom:
+		if d.Min < 0 || d.Max < d.Min {
+			return fmt.Errorf("%s: bounds must satisfy 0 <= min <= max", d)
+		}
+	default:
+		return fmt.Errorf("unknown latency mode: %s (must be off, recorded, fixed, or random)", d.Mode)
+	}
+	return nil
+}
+
+// WebSocket replay modes
+const (
+	WebSocketReplayTimed     = "timed"     // Send server messages at their recorded offsets
+	WebSocketReplayTriggered = "triggered" // Send server messages in reply to matching client messages
+)
+
+// WebSocketConfig controls how recorded WebSocket sessions are replayed
+type WebSocketConfig struct {
+	// Replay is timed or triggered; either way delays are divided by Stream.Speed
+	Replay string `yaml:"replay" toml:"replay"`
+}
+
+// StreamConfig controls how streamed responses (SSE, chunked) are replayed
+type StreamConfig struct {
+	// Speed divides the recorded delays between chunks: 1 keeps the original
+	// pacing, 2 replays twice as fast, and 0 sends every chunk immediately
+	Speed float64 `yaml:"speed" toml:"speed"`
+}
+
+// Sequence end behaviors, applied when replay runs past the last recorded response
+const (
+	SequenceEndLoop     = "loop" // Start over from the first response
+	SequenceEndLast     = "last" // Keep serving the last response
+	SequenceEndNotFound = "404"  // Answer 404 Not Found
+)
+
+// SequenceConfig controls recording and replaying several responses for the same key
+type SequenceConfig struct {
+	Record bool   `yaml:"record" toml:"record"` // Append repeated responses for a key within a session instead of overwriting
+	End    string `yaml:"end" toml:"end"`       // What replay does after the last response: loop, last, or 404
+}
+
+// FuzzyConfig controls the nearest-match fallback used when replay finds no exact recording
+type FuzzyConfig struct {
+	Enabled   bool    `yaml:"enabled" toml:"enabled"`     // Serve the most similar recording with the same method and path
+	Threshold float64 `yaml:"threshold" toml:"threshold"` // Minimum similarity (0-1) a recording needs to be substituted
+}
+
+// HashConfig controls which parts of a request make up its cache key
+type HashConfig struct {
+	Strategy string       `yaml:"strategy" toml:"strategy"` // Matching strategy for requests not covered by Rules
+	Rules    []MatchRule  `yaml:"rules" toml:"rules"`       // Per-route matching strategies, first match wins
+	Query    QueryConfig  `yaml:"query" toml:"query"`
+	Headers  HeaderConfig `yaml:"headers" toml:"headers"`
+	Body     BodyConfig   `yaml:"body" toml:"body"`
+}
+
+// MatchRule selects the matching strategy for requests matching a route pattern
+type MatchRule struct {
+	Match    string   `yaml:"match" toml:"match"`       // Route pattern such as "GET /api/users/{id}"
+	Strategy string   `yaml:"strategy" toml:"strategy"` // default, exact, path-template, json, or header
+	Headers  []string `yaml:"headers" toml:"headers"`   // Hashed headers for the header strategy (defaults to HASH_HEADERS)
+}
+
+// QueryConfig controls how the query string participates in the cache key
+type QueryConfig struct {
+	Enabled  bool     `yaml:"enabled" toml:"enabled"`   // Include the query string in the hash
+	Sort     bool     `yaml:"sort" toml:"sort"`         // Sort parameters by key before hashing
+	Ignore   []string `yaml:"ignore" toml:"ignore"`     // Parameters that never affect the hash
+	Repeated string   `yaml:"repeated" toml:"repeated"` // How repeated keys are treated: preserve, sort, first, or last
+}
+
+// HeaderConfig selects the request headers that participate in the cache key
+type HeaderConfig struct {
+	Include []string `yaml:"include" toml:"include"` // Headers whose values are hashed
+	Secret  []string `yaml:"secret" toml:"secret"`   // Headers whose values are digested before hashing (always included)
+}
+
+// BodyConfig controls how the request body participates in the cache key
+type BodyConfig struct {
+	Mode   string   `yaml:"mode" toml:"mode"`     // raw hashes bytes as-is, json hashes a canonical form of JSON bodies
+	Ignore []string `yaml:"ignore" toml:"ignore"` // JSONPath expressions removed from JSON bodies before hashing
 }
 
 // LoadOptions are optional command-line arguments for configuration
 type LoadOptions struct {
-	Port    *int
-	Backend *string
+	ConfigFile  string // Config file to load instead of CHAMELEON_CONFIG
+	Mode        string
+	Port        *int
+	Backend     *string
+	StoragePath string
+	Cassette    string
 }
 
-// Load loads configuration from command-line arguments, environment variables, and defaults
-// Command-line arguments take precedence over environment variables
+// Load loads configuration from defaults, the config file, the rules file,
+// environment variables, and command-line arguments, each taking precedence
+// over the ones before it
 func Load(opts *LoadOptions) (*Config, error) {
 	cfg := &Config{
 		Mode:        ModeRecord,
 		BackendURL:  "http://localhost:8080",
 		Port:        3000,
 		StoragePath: "./recordings",
+		Storage:     storage.BackendFile,
+		Hash: HashConfig{
+			Strategy: "default",
+			Query: QueryConfig{
+				Enabled:  true,
+				Sort:     true,
+				Ignore:   []string{"_"},
+				Repeated: "preserve",
+			},
+			Body: BodyConfig{
+				Mode: "raw",
+			},
+		},
+		Fuzzy: FuzzyConfig{
+			Threshold: 0.5,
+		},
+		Sequence: SequenceConfig{
+			End: SequenceEndLast,
+		},
+		Stream: StreamConfig{
+			Speed: 1,
+		},
+		WebSocket: WebSocketConfig{
+			Replay: WebSocketReplayTimed,
+		},
+		Latency: LatencyConfig{
+			Delay: Delay{Mode: LatencyOff},
+		},
+	}
+
+	// Problems with settings are collected and reported together
+	v := &validator{}
+
+	// Load the config file - command-line takes precedence
+	configFile := os.Getenv("CHAMELEON_CONFIG")
+	if opts != nil && opts.ConfigFile != "" {
+		configFile = opts.ConfigFile
+	}
+	if configFile != "" {
+		if err := loadFile(configFile, cfg, v); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = configFile
 	}
 
-	// Load mode from environment
-	if modeStr := os.Getenv("MODE"); modeStr != "" {
+	// Load routes, transform and rewrite rules from the rules file
+	if rulesFile := os.Getenv("RULES_FILE"); rulesFile != "" {
+		cfg.RulesFile = rulesFile
+	}
+	if cfg.RulesFile != "" {
+		if err := loadRulesFile(cfg.RulesFile, cfg, v); err != nil {
+			return nil, err
+		}
+	}
+	cfg.prepareRules()
+
+	// Load mode - command-line takes precedence
+	if opts != nil && opts.Mode != "" {
+		cfg.Mode = Mode(strings.ToLower(opts.Mode))
+	} else if modeStr := os.Getenv("MODE"); modeStr != "" {
 		mode := Mode(strings.ToLower(modeStr))
-		if mode != ModeRecord && mode != ModeReplay && mode != ModePassthrough {
-			return nil, fmt.Errorf("invalid MODE: %s (must be record, replay, or passthrough)", modeStr)
+		if mode.Valid() {
+			cfg.Mode = mode
+		} else {
+			v.errorf("mode", "invalid MODE %q (must be record, replay, passthrough, record-missing, or replay-passthrough)", modeStr)
 		}
-		cfg.Mode = mode
 	}
 
 	// Load backend URL - command-line takes precedence
@@ -56,33 +363,307 @@ func Load(opts *LoadOptions) (*Config, error) {
 		cfg.BackendURL = backendURL
 	}
 
+	// Load backend routes from environment
+	if routes := os.Getenv("BACKEND_ROUTES"); routes != "" {
+		cfg.Routes = parseBackendRoutes(routes, v)
+	}
+
 	// Load port - command-line takes precedence
 	if opts != nil && opts.Port != nil {
 		cfg.Port = *opts.Port
 	} else if portStr := os.Getenv("PORT"); portStr != "" {
-		port, err := strconv.Atoi(portStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid PORT: %s", portStr)
+		if port, err := strconv.Atoi(portStr); err == nil {
+			cfg.Port = port
+		} else {
+			v.errorf("port", "invalid PORT %q (must be a number)", portStr)
 		}
-		if port < 1 || port > 65535 {
-			return nil, fmt.Errorf("invalid PORT: %d (must be between 1 and 65535)", port)
-		}
-		cfg.Port = port
 	}
 
-	// Load storage path from environment
-	if storagePath := os.Getenv("STORAGE_PATH"); storagePath != "" {
+	// Load storage path - command-line takes precedence
+	if opts != nil && opts.StoragePath != "" {
+		cfg.StoragePath = opts.StoragePath
+	} else if storagePath := os.Getenv("STORAGE_PATH"); storagePath != "" {
 		cfg.StoragePath = storagePath
 	}
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" {
+		cfg.Storage = backend
+	}
+
+	// Load cassette - command-line takes precedence
+	if opts != nil && opts.Cassette != "" {
+		cfg.Cassette = opts.Cassette
+	} else if cassette := os.Getenv("CASSETTE"); cassette != "" {
+		cfg.Cassette = cassette
+	}
+
+	// Load query hashing options from environment
+	loadQueryConfig(&cfg.Hash.Query, v)
+
+	// Load header hashing options from environment
+	if headers, ok := os.LookupEnv("HASH_HEADERS"); ok {
+		cfg.Hash.Headers.Include = splitList(headers)
+	}
+	if secret, ok := os.LookupEnv("HASH_SECRET_HEADERS"); ok {
+		cfg.Hash.Headers.Secret = splitList(secret)
+	}
+
+	// Load matching strategies from environment
+	if strategy := os.Getenv("HASH_STRATEGY"); strategy != "" {
+		cfg.Hash.Strategy = strings.ToLower(strategy)
+	}
+	if rules := os.Getenv("HASH_RULES"); rules != "" {
+		cfg.Hash.Rules = parseMatchRules(rules, v)
+	}
+
+	// Load body hashing options from environment
+	if bodyMode := os.Getenv("HASH_BODY"); bodyMode != "" {
+		cfg.Hash.Body.Mode = strings.ToLower(bodyMode)
+	}
+	if ignore, ok := os.LookupEnv("HASH_BODY_IGNORE"); ok {
+		cfg.Hash.Body.Ignore = splitList(ignore)
+	}
+
+	// Load nearest-match fallback options from environment
+	cfg.Fuzzy.Enabled = envBool(v, "fuzzy.enabled", "FUZZY_MATCH", cfg.Fuzzy.Enabled)
+	if threshold := os.Getenv("FUZZY_THRESHOLD"); threshold != "" {
+		if t, err := strconv.ParseFloat(threshold, 64); err == nil {
+			cfg.Fuzzy.Threshold = t
+		} else {
+			v.errorf("fuzzy.threshold", "invalid FUZZY_THRESHOLD %q (must be a number)", threshold)
+		}
+	}
+
+	// Load response sequence options from environment
+	cfg.Sequence.Record = envBool(v, "sequence.record", "SEQUENCE_RECORD", cfg.Sequence.Record)
+	if end := os.Getenv("SEQUENCE_END"); end != "" {
+		cfg.Sequence.End = strings.ToLower(end)
+	}
+
+	// Load stream replay options from environment
+	if speed := os.Getenv("STREAM_SPEED"); speed != "" {
+		if s, err := strconv.ParseFloat(speed, 64); err == nil {
+			cfg.Stream.Speed = s
+		} else {
+			v.errorf("stream.speed", "invalid STREAM_SPEED %q (must be a number)", speed)
+		}
+	}
+
+	// Load WebSocket replay options from environment
+	if replay := os.Getenv("WS_REPLAY"); replay != "" {
+		cfg.WebSocket.Replay = strings.ToLower(replay)
+	}
+
+	// Load latency simulation options from environment
+	if latency := os.Getenv("LATENCY"); latency != "" {
+		if delay, err := ParseDelay(latency); err == nil {
+			cfg.Latency.Delay = delay
+		} else {
+			v.errorf("latency.delay", "invalid LATENCY: %w", err)
+		}
+	}
+	if rules := os.Getenv("LATENCY_RULES"); rules != "" {
+		cfg.Latency.Rules = parseLatencyRules(rules, v)
+	}
+
+	// Load fault injection rules from environment
+	if rules := os.Getenv("FAULT_RULES"); rules != "" {
+		cfg.Faults = parseFaultRules(rules, v)
+	}
 
 	// Validate configuration
-	if err := cfg.Validate(); err != nil {
+	cfg.validate(v)
+	if err := v.err(); err != nil {
 		return nil, err
 	}
 
 	return cfg, nil
 }
 
+// loadQueryConfig applies the HASH_QUERY* environment variables to qc
+func loadQueryConfig(qc *QueryConfig, v *validator) {
+	qc.Enabled = envBool(v, "hash.query.enabled", "HASH_QUERY", qc.Enabled)
+	qc.Sort = envBool(v, "hash.query.sort", "HASH_QUERY_SORT", qc.Sort)
+	if ignore, ok := os.LookupEnv("HASH_QUERY_IGNORE"); ok {
+		qc.Ignore = splitList(ignore)
+	}
+	if repeated := os.Getenv("HASH_QUERY_REPEATED"); repeated != "" {
+		qc.Repeated = strings.ToLower(repeated)
+	}
+}
+
+// parseMatchRules parses HASH_RULES, a semicolon-separated list of
+// "pattern=strategy" entries where the header strategy may list its headers
+// after a colon, e.g. "GET /api/users/{id}=path-template;/api/i18n/*=header:Accept-Language|Accept"
+func parseMatchRules(value string, v *validator) []MatchRule {
+	var rules []MatchRule
+	for n, entry := range splitEntries(value) {
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			v.errorf(fmt.Sprintf("hash.rules[%d]", n), "invalid HASH_RULES entry %q (expected pattern=strategy)", entry)
+			continue
+		}
+		rule := MatchRule{Match: strings.TrimSpace(entry[:i])}
+
+		strategy, args, _ := strings.Cut(entry[i+1:], ":")
+		rule.Strategy = strings.ToLower(strings.TrimSpace(strategy))
+		for _, header := range strings.Split(args, "|") {
+			if header = strings.TrimSpace(header); header != "" {
+				rule.Headers = append(rule.Headers, header)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// parseBackendRoutes parses BACKEND_ROUTES, a semicolon-separated list of
+// "name [METHOD] match backend" entries where match is a path pattern, a host,
+// or a host followed by a path pattern, e.g.
+// "auth /auth/* http://localhost:9001;cdn cdn.example.test http://localhost:9002"
+func parseBackendRoutes(value string, v *validator) []BackendRoute {
+	var routes []BackendRoute
+	for n, entry := range splitEntries(value) {
+		fields := strings.Fields(entry)
+		if len(fields) != 3 && len(fields) != 4 {
+			v.errorf(fmt.Sprintf("routes[%d]", n), "invalid BACKEND_ROUTES entry %q (expected name [METHOD] match backend)", entry)
+			continue
+		}
+
+		rt := BackendRoute{Name: fields[0], Backend: normalizeBackendURL(fields[len(fields)-1])}
+		match := fields[len(fields)-2]
+		if !strings.HasPrefix(match, "/") && match != "*" {
+			rt.Host, match, _ = strings.Cut(match, "/")
+			match = "/" + match
+			if match == "/" {
+				match = "*"
+			}
+		}
+		if len(fields) == 4 {
+			match = fields[1] + " " + match
+		}
+		rt.Match = match
+
+		routes = append(routes, rt)
+	}
+	return routes
+}
+
+// parseLatencyRules parses LATENCY_RULES, a semicolon-separated list of
+// "pattern=delay" entries, e.g. "GET /api/search=random:100ms-800ms;/api/reports/*=fixed:2s"
+func parseLatencyRules(value string, v *validator) []LatencyRule {
+	var rules []LatencyRule
+	for n, entry := range splitEntries(value) {
+		field := fmt.Sprintf("latency.rules[%d]", n)
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			v.errorf(field, "invalid LATENCY_RULES entry %q (expected pattern=delay)", entry)
+			continue
+		}
+		delay, err := ParseDelay(entry[i+1:])
+		if err != nil {
+			v.errorf(field, "invalid LATENCY_RULES entry %q: %w", entry, err)
+			continue
+		}
+		rules = append(rules, LatencyRule{Match: strings.TrimSpace(entry[:i]), Delay: delay})
+	}
+	return rules
+}
+
+// parseFaultRules parses FAULT_RULES, a semicolon-separated list of
+// "pattern=action[:arg][@probability]" entries, e.g.
+// "POST /api/checkout=status:503@0.25;GET /api/feed=truncate:512;/api/slow/*=hang@0.1"
+func parseFaultRules(value string, v *validator) []FaultRule {
+	var rules []FaultRule
+	for n, entry := range splitEntries(value) {
+		field := fmt.Sprintf("faults[%d]", n)
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			v.errorf(field, "invalid FAULT_RULES entry %q (expected pattern=action)", entry)
+			continue
+		}
+		rule := FaultRule{Match: strings.TrimSpace(entry[:i]), Probability: 1}
+
+		spec, probability, hasProbability := strings.Cut(entry[i+1:], "@")
+		if hasProbability {
+			p, err := strconv.ParseFloat(strings.TrimSpace(probability), 64)
+			if err != nil {
+				v.errorf(field, "invalid FAULT_RULES entry %q (invalid probability)", entry)
+				continue
+			}
+			rule.Probability = p
+		}
+
+		action, arg, hasArg := strings.Cut(spec, ":")
+		rule.Action = strings.ToLower(strings.TrimSpace(action))
+		if hasArg {
+			number, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil {
+				v.errorf(field, "invalid FAULT_RULES entry %q (argument must be a number)", entry)
+				continue
+			}
+			switch rule.Action {
+			case FaultStatus:
+				rule.Status = number
+			case FaultTruncate:
+				rule.Bytes = number
+			default:
+				v.errorf(field, "invalid FAULT_RULES entry %q (%s takes no argument)", entry, rule.Action)
+				continue
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// splitEntries splits a semicolon-separated list of rules, trimming
+// whitespace and dropping empty entries
+func splitEntries(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// envBool reads a boolean environment variable for the setting field,
+// returning def if it is unset or invalid
+func envBool(v *validator, field, name string, def bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		v.errorf(field, "invalid %s %q (must be true or false)", name, value)
+		return def
+	}
+	return b
+}
+
+// splitList splits a comma-separated list, trimming whitespace and dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// namespacePattern restricts route names, which become storage directories
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validNamespace reports whether name can be used as a storage namespace
+func validNamespace(name string) bool {
+	return namespacePattern.MatchString(name)
+}
+
 // normalizeBackendURL ensures the backend URL has a scheme (http:// or https://)
 func normalizeBackendURL(backend string) string {
 	backend = strings.TrimSpace(backend)
@@ -98,20 +679,3 @@ func normalizeBackendURL(backend string) string {
 	// Otherwise, assume http://
 	return "http://" + backend
 }
-
-// Validate validates the configuration
-func (c *Config) Validate() error {
-	if c.BackendURL == "" {
-		return fmt.Errorf("BACKEND_URL cannot be empty")
-	}
-
-	if c.Port < 1 || c.Port > 65535 {
-		return fmt.Errorf("invalid PORT: %d (must be between 1 and 65535)", c.Port)
-	}
-
-	if c.StoragePath == "" {
-		return fmt.Errorf("STORAGE_PATH cannot be empty")
-	}
-
-	return nil
-}
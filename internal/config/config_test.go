@@ -0,0 +1,472 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// envVars are the environment variables Load reads
+var envVars = []string{
+	"CHAMELEON_CONFIG", "RULES_FILE", "MODE", "BACKEND_URL", "BACKEND_ROUTES", "PORT",
+	"STORAGE_PATH", "STORAGE_BACKEND", "CASSETTE",
+	"HASH_QUERY", "HASH_QUERY_SORT", "HASH_QUERY_IGNORE", "HASH_QUERY_REPEATED",
+	"HASH_HEADERS", "HASH_SECRET_HEADERS", "HASH_STRATEGY", "HASH_RULES", "HASH_BODY", "HASH_BODY_IGNORE",
+	"FUZZY_MATCH", "FUZZY_THRESHOLD", "SEQUENCE_RECORD", "SEQUENCE_END", "STREAM_SPEED", "WS_REPLAY",
+	"LATENCY", "LATENCY_RULES", "FAULT_RULES",
+}
+
+// setEnv clears the environment Load reads, then sets env for the test
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range envVars {
+		// Setenv restores the previous value when the test ends
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+// writeFile writes a file named name into a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// fields returns the settings named by a *ValidationError
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error %v is not a *ValidationError", err)
+	}
+	var names []string
+	for _, fe := range verr.Errors {
+		names = append(names, fe.Field)
+	}
+	return names
+}
+
+func TestLoadDefaults(t *testing.T) {
+	setEnv(t, nil)
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mode != ModeRecord || cfg.Port != 3000 || cfg.BackendURL != "http://localhost:8080" || cfg.StoragePath != "./recordings" {
+		t.Errorf("Load = mode %s, port %d, backend %s, storage %s", cfg.Mode, cfg.Port, cfg.BackendURL, cfg.StoragePath)
+	}
+	if !cfg.Hash.Query.Enabled || !cfg.Hash.Query.Sort || !reflect.DeepEqual(cfg.Hash.Query.Ignore, []string{"_"}) {
+		t.Errorf("query hashing = %+v, want enabled and sorted, ignoring _", cfg.Hash.Query)
+	}
+	if cfg.Latency.Delay.Mode != LatencyOff || cfg.Sequence.End != SequenceEndLast || cfg.Stream.Speed != 1 {
+		t.Errorf("Load = latency %s, sequence end %s, stream speed %g", cfg.Latency.Delay, cfg.Sequence.End, cfg.Stream.Speed)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "chameleon.yaml", `
+mode: REPLAY
+port: 4000
+backend: api.example.test
+storage_path: /from/file
+cassette: file
+hash:
+  strategy: exact
+latency:
+  delay: fixed:100ms
+`)
+	port := 6000
+	backend := "flag.example.test"
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		opts *LoadOptions
+		want Config
+	}{
+		{
+			name: "file",
+			env:  map[string]string{"CHAMELEON_CONFIG": file},
+			want: Config{Mode: ModeReplay, Port: 4000, BackendURL: "http://api.example.test", StoragePath: "/from/file", Cassette: "file"},
+		},
+		{
+			name: "environment over file",
+			env: map[string]string{
+				"CHAMELEON_CONFIG": file,
+				"MODE":             "passthrough",
+				"PORT":             "5000",
+				"BACKEND_URL":      "http://env.example.test",
+				"STORAGE_PATH":     "/from/env",
+				"CASSETTE":         "env",
+			},
+			want: Config{Mode: ModePassthrough, Port: 5000, BackendURL: "http://env.example.test", StoragePath: "/from/env", Cassette: "env"},
+		},
+		{
+			name: "flags over environment",
+			env: map[string]string{
+				"CHAMELEON_CONFIG": "/does/not/exist.yaml",
+				"MODE":             "passthrough",
+				"PORT":             "5000",
+				"BACKEND_URL":      "http://env.example.test",
+			},
+			opts: &LoadOptions{ConfigFile: file, Mode: "Record-Missing", Port: &port, Backend: &backend, StoragePath: "/from/flag", Cassette: "flag"},
+			want: Config{Mode: ModeRecordMissing, Port: 6000, BackendURL: "http://flag.example.test", StoragePath: "/from/flag", Cassette: "flag"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			cfg, err := Load(tt.opts)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			got := Config{Mode: cfg.Mode, Port: cfg.Port, BackendURL: cfg.BackendURL, StoragePath: cfg.StoragePath, Cassette: cfg.Cassette}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load = %+v, want %+v", got, tt.want)
+			}
+			if cfg.ConfigFile != file {
+				t.Errorf("ConfigFile = %q, want %q", cfg.ConfigFile, file)
+			}
+			// Settings only the file sets keep their value from the file
+			if cfg.Hash.Strategy != "exact" || cfg.Latency.Delay != (Delay{Mode: LatencyFixed, Fixed: 100 * time.Millisecond}) {
+				t.Errorf("Load = strategy %s, latency %s, want the values of the file", cfg.Hash.Strategy, cfg.Latency.Delay)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLAndTOML(t *testing.T) {
+	files := map[string]string{
+		"chameleon.yaml": `
+mode: replay
+routes:
+  - name: auth
+    match: /auth/*
+    backend: http://localhost:9001
+hash:
+  rules:
+    - match: GET /api/users/{id}
+      strategy: path-template
+  query:
+    ignore: [_, nonce]
+latency:
+  rules:
+    - match: GET /api/search
+      delay: random:100ms-800ms
+faults:
+  - match: POST /api/checkout
+    action: status
+    status: 503
+`,
+		"chameleon.toml": `
+mode = "replay"
+
+[[routes]]
+name = "auth"
+match = "/auth/*"
+backend = "http://localhost:9001"
+
+[[hash.rules]]
+match = "GET /api/users/{id}"
+strategy = "path-template"
+
+[hash.query]
+ignore = ["_", "nonce"]
+
+[[latency.rules]]
+match = "GET /api/search"
+delay = "random:100ms-800ms"
+
+[[faults]]
+match = "POST /api/checkout"
+action = "status"
+status = 503
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			setEnv(t, nil)
+			cfg, err := Load(&LoadOptions{ConfigFile: writeFile(t, name, content)})
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Mode != ModeReplay {
+				t.Errorf("mode = %s, want replay", cfg.Mode)
+			}
+			wantRoutes := []BackendRoute{{Name: "auth", Match: "/auth/*", Backend: "http://localhost:9001"}}
+			if !reflect.DeepEqual(cfg.Routes, wantRoutes) {
+				t.Errorf("routes = %+v, want %+v", cfg.Routes, wantRoutes)
+			}
+			wantRules := []MatchRule{{Match: "GET /api/users/{id}", Strategy: "path-template"}}
+			if !reflect.DeepEqual(cfg.Hash.Rules, wantRules) {
+				t.Errorf("hash rules = %+v, want %+v", cfg.Hash.Rules, wantRules)
+			}
+			if !reflect.DeepEqual(cfg.Hash.Query.Ignore, []string{"_", "nonce"}) || !cfg.Hash.Query.Sort {
+				t.Errorf("query = %+v, want nonce ignored and the defaults kept", cfg.Hash.Query)
+			}
+			wantLatency := []LatencyRule{{Match: "GET /api/search", Delay: Delay{Mode: LatencyRandom, Min: 100 * time.Millisecond, Max: 800 * time.Millisecond}}}
+			if !reflect.DeepEqual(cfg.Latency.Rules, wantLatency) {
+				t.Errorf("latency rules = %+v, want %+v", cfg.Latency.Rules, wantLatency)
+			}
+			// Fault rules without a probability always fire
+			wantFaults := []FaultRule{{Match: "POST /api/checkout", Action: FaultStatus, Status: 503, Probability: 1}}
+			if !reflect.DeepEqual(cfg.Faults, wantFaults) {
+				t.Errorf("faults = %+v, want %+v", cfg.Faults, wantFaults)
+			}
+		})
+	}
+}
+
+func TestLoadEnvironmentRules(t *testing.T) {
+	setEnv(t, map[string]string{
+		"BACKEND_ROUTES": "auth /auth/* localhost:9001; cdn cdn.example.test http://localhost:9002",
+		"HASH_RULES":     "GET /api/users/{id}=path-template;/api/i18n/*=header:Accept-Language|Accept",
+		"LATENCY_RULES":  "/api/reports/*=fixed:2s",
+		"FAULT_RULES":    "POST /api/checkout=status:503@0.25;GET /api/feed=truncate:512",
+	})
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	wantRoutes := []BackendRoute{
+		{Name: "auth", Match: "/auth/*", Backend: "http://localhost:9001"},
+		{Name: "cdn", Match: "*", Host: "cdn.example.test", Backend: "http://localhost:9002"},
+	}
+	if !reflect.DeepEqual(cfg.Routes, wantRoutes) {
+		t.Errorf("routes = %+v, want %+v", cfg.Routes, wantRoutes)
+	}
+	wantRules := []MatchRule{
+		{Match: "GET /api/users/{id}", Strategy: "path-template"},
+		{Match: "/api/i18n/*", Strategy: "header", Headers: []string{"Accept-Language", "Accept"}},
+	}
+	if !reflect.DeepEqual(cfg.Hash.Rules, wantRules) {
+		t.Errorf("hash rules = %+v, want %+v", cfg.Hash.Rules, wantRules)
+	}
+	wantLatency := []LatencyRule{{Match: "/api/reports/*", Delay: Delay{Mode: LatencyFixed, Fixed: 2 * time.Second}}}
+	if !reflect.DeepEqual(cfg.Latency.Rules, wantLatency) {
+		t.Errorf("latency rules = %+v, want %+v", cfg.Latency.Rules, wantLatency)
+	}
+	wantFaults := []FaultRule{
+		{Match: "POST /api/checkout", Action: FaultStatus, Status: 503, Probability: 0.25},
+		{Match: "GET /api/feed", Action: FaultTruncate, Bytes: 512, Probability: 1},
+	}
+	if !reflect.DeepEqual(cfg.Faults, wantFaults) {
+		t.Errorf("faults = %+v, want %+v", cfg.Faults, wantFaults)
+	}
+}
+
+func TestLoadValidationErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		fields []string
+	}{
+		{
+			name:   "invalid values",
+			env:    map[string]string{"PORT": "70000", "FUZZY_THRESHOLD": "1.5", "SEQUENCE_END": "stop", "STORAGE_BACKEND": "redis"},
+			fields: []string{"port", "storage_backend", "fuzzy.threshold", "sequence.end"},
+		},
+		{
+			name:   "unparsable values",
+			env:    map[string]string{"MODE": "rewind", "PORT": "http", "FUZZY_MATCH": "maybe", "STREAM_SPEED": "fast", "LATENCY": "soon"},
+			fields: []string{"mode", "port", "fuzzy.enabled", "stream.speed", "latency.delay"},
+		},
+		{
+			name: "rules by index",
+			env: map[string]string{
+				"BACKEND_ROUTES": "auth /auth/* http://localhost:9001;broken",
+				"HASH_RULES":     "/a=exact;/b=guess",
+				"LATENCY_RULES":  "/a=fixed:1s;/b=later",
+				"FAULT_RULES":    "/a=drop;/b=explode",
+			},
+			fields: []string{"routes[1]", "latency.rules[1]", "hash.rules[1].strategy", "faults[1].action"},
+		},
+		{
+			name:   "path-template needs a route",
+			env:    map[string]string{"HASH_STRATEGY": "path-template"},
+			fields: []string{"hash.strategy"},
+		},
+		{
+			name:   "header strategy without headers",
+			env:    map[string]string{"HASH_RULES": "/api/*=header"},
+			fields: []string{"hash.rules[0].headers"},
+		},
+		{
+			name:   "invalid ignore expressions",
+			env:    map[string]string{"HASH_BODY": "xml", "HASH_BODY_IGNORE": "$.ok,$.items["},
+			fields: []string{"hash.body.mode", "hash.body.ignore[1]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			_, err := Load(nil)
+			if err == nil {
+				t.Fatalf("Load succeeded, want errors for %v", tt.fields)
+			}
+			if got := fields(t, err); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("Load reported %v, want %v\n%v", got, tt.fields, err)
+			}
+		})
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		fields  []string
+	}{
+		{
+			name: "yaml",
+			file: "chameleon.yaml",
+			content: `
+port: many
+hash:
+  strategy: guess
+  rules:
+    - match: /a
+      strategy: exact
+    - match: /b
+      strategy: [exact]
+latency:
+  delay: soon
+fuzzy:
+  enabeld: true
+`,
+			fields: []string{"port", "hash.rules[1].strategy", "latency.delay", "fuzzy.enabeld", "hash.strategy"},
+		},
+		{
+			name: "toml",
+			file: "chameleon.toml",
+			content: `
+port = 3000
+mode = "replay"
+colour = "green"
+
+[hash]
+strategy = "guess"
+`,
+			fields: []string{"colour", "hash.strategy"},
+		},
+		{
+			name: "toml types",
+			file: "chameleon.toml",
+			content: `
+port = "many"
+
+[hash]
+strategy = 1
+
+[[hash.rules]]
+match = "/a"
+strategy = "exact"
+
+[[hash.rules]]
+match = "/b"
+strategy = ["exact"]
+
+[latency]
+delay = "soon"
+
+[fuzzy]
+enabeld = true
+
+[[faults]]
+match = "/c"
+action = "drop"
+chance = 0.5
+`,
+			fields: []string{"port", "hash.strategy", "hash.rules[1].strategy", "fuzzy.enabeld", "latency.delay", "faults[0]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, nil)
+			_, err := Load(&LoadOptions{ConfigFile: writeFile(t, tt.file, tt.content)})
+			if err == nil {
+				t.Fatalf("Load succeeded, want errors for %v", tt.fields)
+			}
+			if got := fields(t, err); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("Load reported %v, want %v\n%v", got, tt.fields, err)
+			}
+		})
+	}
+}
+
+func TestLoadFileUnreadable(t *testing.T) {
+	setEnv(t, nil)
+	for _, path := range []string{
+		filepath.Join(t.TempDir(), "missing.yaml"),
+		writeFile(t, "chameleon.json", `{}`),
+		writeFile(t, "chameleon.yaml", "mode: [replay"),
+	} {
+		_, err := Load(&LoadOptions{ConfigFile: path})
+		var verr *ValidationError
+		if err == nil || errors.As(err, &verr) {
+			t.Errorf("Load(%s) = %v, want an error reading the file", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestLoadRulesFile(t *testing.T) {
+	dir := t.TempDir()
+	rules := `{
+  "routes": [{"name": "auth", "match": "/auth/*", "backend": "localhost:9001"}],
+  "rewrites": [{"headers": {"set": {"Authorization": "Bearer ${TEST_TOKEN}"}}}]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "rules.json"), []byte(rules), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	file := filepath.Join(dir, "chameleon.yaml")
+	if err := os.WriteFile(file, []byte("rules_file: rules.json\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	setEnv(t, map[string]string{"TEST_TOKEN": "secret"})
+	cfg, err := Load(&LoadOptions{ConfigFile: file})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// The rules file is found next to the config file, and its entries get defaults
+	wantRoutes := []BackendRoute{{Name: "auth", Match: "/auth/*", Backend: "http://localhost:9001"}}
+	if !reflect.DeepEqual(cfg.Routes, wantRoutes) {
+		t.Errorf("routes = %+v, want %+v", cfg.Routes, wantRoutes)
+	}
+	if len(cfg.Rewrites) != 1 || cfg.Rewrites[0].Match != "*" || cfg.Rewrites[0].Headers.Set["Authorization"] != "Bearer secret" {
+		t.Errorf("rewrites = %+v, want a rewrite of every request with the token expanded", cfg.Rewrites)
+	}
+}
+
+func TestLoadRulesFileErrors(t *testing.T) {
+	rules := writeFile(t, "rules.json", `{
+  "routes": [
+    {"name": "auth", "match": "/auth/*", "backend": "localhost:9001"},
+    {"name": "cdn", "backend": "localhost:9002", "hots": "cdn.local"}
+  ],
+  "rewrites": [
+    {"match": "/api/*", "headers": {"sett": {"X-Env": "staging"}}},
+    {"match": "/api/*", "path": {"from": "/api", "to": "/v2", "regex": "yes"}}
+  ],
+  "rewirtes": []
+}`)
+
+	setEnv(t, map[string]string{"RULES_FILE": rules})
+	_, err := Load(nil)
+	want := []string{"rewirtes", "rewrites[0]", "rewrites[1].path.regex", "routes[1].hots"}
+	if got := fields(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("Load reported %v, want %v\n%v", got, want, err)
+	}
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads the YAML or TOML config file at path into cfg, chosen by its
+// extension. Settings missing from the file keep their current values
+// Settings of the wrong type or unknown to Chameleon are recorded in v; files
+// that can't be read or parsed at all are reported as errors
+func loadFile(path string, cfg *Config, v *validator) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err := dec.Decode(cfg)
+		var typeErr *yaml.TypeError
+		switch {
+		case errors.As(err, &typeErr):
+			// Decoding went on past these, so report them all
+			fields := yamlFields(data)
+			for _, problem := range typeErr.Errors {
+				field, msg := filepath.Base(path), problem
+				if m := yamlLinePattern.FindStringSubmatch(problem); m != nil {
+					line, _ := strconv.Atoi(m[1])
+					if name, ok := fields[line]; ok {
+						field, msg = name, m[2]
+					}
+				}
+				v.errorf(field, "%s", msg)
+			}
+		case err != nil && !errors.Is(err, io.EOF):
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		var table map[string]toml.Primitive
+		meta, err := toml.Decode(string(data), &table)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		decodeTOMLTable(meta, table, reflect.ValueOf(cfg).Elem(), "", v)
+	default:
+		return fmt.Errorf("unsupported config file %s (must be .yaml, .yml, or .toml)", path)
+	}
+
+	cfg.Mode = Mode(strings.ToLower(string(cfg.Mode)))
+	cfg.BackendURL = normalizeBackendURL(cfg.BackendURL)
+
+	// A relative rules file is found next to the config file
+	if cfg.RulesFile != "" && !filepath.IsAbs(cfg.RulesFile) {
+		cfg.RulesFile = filepath.Join(filepath.Dir(path), cfg.RulesFile)
+	}
+	return nil
+}
+
+// yamlLinePattern splits the line number off the errors of yaml.TypeError
+var yamlLinePattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// tomlKeyPattern picks the problem out of TOML decoding errors
+var tomlKeyPattern = regexp.MustCompile(`^toml: (?:line \d+ )?\(last key "[^"]+"\): (.*)$`)
+
+// decodeTOMLTable decodes the keys of a TOML table into the struct s, the
+// setting at path. Keys are decoded one by one, and tables and arrays of
+// tables within them likewise, so that as with YAML every setting of the wrong
+// type or unknown to Chameleon is recorded in v, not just the first
+func decodeTOMLTable(meta toml.MetaData, table map[string]toml.Primitive, s reflect.Value, path string, v *validator) {
+	known := make(map[string]bool, len(table))
+	for i := 0; i < s.NumField(); i++ {
+		name, _, _ := strings.Cut(s.Type().Field(i).Tag.Get("toml"), ",")
+		prim, ok := table[name]
+		if name == "" || name == "-" || !ok {
+			continue
+		}
+		known[name] = true
+		decodeTOML(meta, prim, s.Field(i).Addr(), joinField(path, name), v)
+	}
+
+	var unknown []string
+	for name := range table {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		v.errorf(joinField(path, name), "unknown field")
+	}
+}
+
+// decodeTOML decodes the TOML value prim into target, a pointer to the setting
+// at path, recording problems in v
+func decodeTOML(meta toml.MetaData, prim toml.Primitive, target reflect.Value, path string, v *validator) {
+	// Types that decode themselves, such as fault rules and delays, are
+	// decoded as a whole
+	_, custom := target.Interface().(toml.Unmarshaler)
+	_, text := target.Interface().(encoding.TextUnmarshaler)
+	t := target.Elem().Type()
+	switch {
+	case custom || text:
+	case t.Kind() == reflect.Struct:
+		var table map[string]toml.Primitive
+		if err := meta.PrimitiveDecode(prim, &table); err != nil {
+			v.errorf(path, "must be a table")
+			return
+		}
+		decodeTOMLTable(meta, table, target.Elem(), path, v)
+		return
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct:
+		value := reflect.New(t.Elem())
+		decodeTOML(meta, prim, value, path, v)
+		target.Elem().Set(value)
+		return
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		var items []toml.Primitive
+		if err := meta.PrimitiveDecode(prim, &items); err != nil {
+			v.errorf(path, "must be an array of tables")
+			return
+		}
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			decodeTOML(meta, item, slice.Index(i).Addr(), fmt.Sprintf("%s[%d]", path, i), v)
+		}
+		target.Elem().Set(slice)
+		return
+	}
+
+	if err := meta.PrimitiveDecode(prim, target.Interface()); err != nil {
+		msg := err.Error()
+		if m := tomlKeyPattern.FindStringSubmatch(msg); m != nil {
+			msg = m[1]
+		}
+		v.errorf(path, "%s", msg)
+	}
+}
+
+// joinField returns the path of the setting name within the setting at path
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// yamlFields maps the lines of a YAML document to the path of the setting
+// written on them, e.g. hash.rules[1].strategy
+func yamlFields(data []byte) map[int]string {
+	fields := make(map[int]string)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fields
+	}
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				item := fmt.Sprintf("%s[%d]", path, i)
+				// A flow sequence such as [a, b] shares the line of its key
+				if _, ok := fields[child.Line]; !ok {
+					fields[child.Line] = item
+				}
+				walk(child, item)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				name := key.Value
+				if path != "" {
+					name = path + "." + name
+				}
+				fields[key.Line] = name
+				walk(node.Content[i+1], name)
+			}
+		}
+	}
+	walk(&doc, "")
+	return fields
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Delay
+// Invalid delays are reported as type errors, so decoding goes on and the
+// other settings are checked too
+func (d *Delay) UnmarshalYAML(node *yaml.Node) error {
+	if err := d.UnmarshalText([]byte(node.Value)); err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %v", node.Line, err)}}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for FaultRule
+// A rule without a probability always fires, as in FAULT_RULES
+func (f *FaultRule) UnmarshalYAML(node *yaml.Node) error {
+	type plain FaultRule
+	rule := plain{Probability: 1}
+	if err := node.Decode(&rule); err != nil {
+		return err
+	}
+	*f = FaultRule(rule)
+	return nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler for FaultRule
+// A rule without a probability always fires, as in FAULT_RULES
+func (f *FaultRule) UnmarshalTOML(data interface{}) error {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("fault must be a table")
+	}
+
+	// The known fields are decoded even if others are wrong, so the rule is
+	// only reported once
+	rule := FaultRule{Probability: 1}
+	var err error
+	for key, value := range fields {
+		var ok bool
+		switch key {
+		case "match":
+			rule.Match, ok = value.(string)
+		case "action":
+			rule.Action, ok = value.(string)
+		case "status":
+			var n int64
+			n, ok = value.(int64)
+			rule.Status = int(n)
+		case "bytes":
+			var n int64
+			n, ok = value.(int64)
+			rule.Bytes = int(n)
+		case "probability":
+			switch p := value.(type) {
+			case float64:
+				rule.Probability, ok = p, true
+			case int64:
+				rule.Probability, ok = float64(p), true
+			}
+		default:
+			err = fmt.Errorf("unknown fault field %s", key)
+			continue
+		}
+		if !ok {
+			err = fmt.Errorf("fault field %s has the wrong type", key)
+		}
+	}
+	*f = rule
+	return err
+}
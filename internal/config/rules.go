@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFile is the schema of the JSON file named by RULES_FILE
+type RulesFile struct {
+	Routes     []BackendRoute  `json:"routes" yaml:"routes" toml:"routes"`
+	Transforms []TransformRule `json:"transforms" yaml:"transforms" toml:"transforms"`
+	Rewrites   []RewriteRule   `json:"rewrites" yaml:"rewrites" toml:"rewrites"`
+}
+
+// BackendRoute sends requests matching a route pattern, and optionally
+// addressed to a host, to a backend of their own. Their recordings are kept
+// in a storage namespace named after the route, so recordings of different
+// backends never collide
+type BackendRoute struct {
+	Name    string `json:"name" yaml:"name" toml:"name"`           // Storage namespace, e.g. "auth"
+	Match   string `json:"match" yaml:"match" toml:"match"`        // Route pattern such as "/auth/*" (default: every request)
+	Host    string `json:"host,omitempty" yaml:"host" toml:"host"` // Host the request is addressed to, e.g. "cdn.example.test"
+	Backend string `json:"backend" yaml:"backend" toml:"backend"`  // Backend URL
+}
+
+// RewriteRule modifies requests matching a route pattern before they are
+// forwarded to the backend. Header and query values may reference
+// environment variables as ${NAME}, so tokens don't have to live in the file
+type RewriteRule struct {
+	Match   string       `json:"match" yaml:"match" toml:"match"` // Route pattern matched against the client's request
+	Headers ValueEdits   `json:"headers" yaml:"headers" toml:"headers"`
+	Query   ValueEdits   `json:"query" yaml:"query" toml:"query"`
+	Path    *PathRewrite `json:"path,omitempty" yaml:"path" toml:"path"`
+}
+
+// ValueEdits changes the values of request headers or query parameters
+// Deletions are applied first, then Set (replacing existing values), then Add
+type ValueEdits struct {
+	Set    map[string]string `json:"set,omitempty" yaml:"set" toml:"set"`
+	Add    map[string]string `json:"add,omitempty" yaml:"add" toml:"add"`
+	Delete []string          `json:"delete,omitempty" yaml:"delete" toml:"delete"`
+}
+
+// Empty reports whether e changes nothing
+func (e ValueEdits) Empty() bool {
+	return len(e.Set) == 0 && len(e.Add) == 0 && len(e.Delete) == 0
+}
+
+// expandEnv replaces ${NAME} references in the values with environment variables
+func (e ValueEdits) expandEnv() {
+	for key, value := range e.Set {
+		e.Set[key] = os.ExpandEnv(value)
+	}
+	for key, value := range e.Add {
+		e.Add[key] = os.ExpandEnv(value)
+	}
+}
+
+// PathRewrite replaces the start of the request path, or with Regex every
+// match of a regular expression ($1 refers to groups)
+type PathRewrite struct {
+	From  string `json:"from" yaml:"from" toml:"from"`
+	To    string `json:"to" yaml:"to" toml:"to"`
+	Regex bool   `json:"regex,omitempty" yaml:"regex" toml:"regex"`
+}
+
+// TransformRule applies a response transform to replayed responses of matching requests
+// In the rules file every key besides match and type is a type-specific option:
+//
+//	{"match": "GET /api/orders/*", "type": "refresh-dates", "paths": ["$..createdAt"]}
+type TransformRule struct {
+	Match   string                 // Route pattern such as "GET /api/orders/*"
+	Type    string                 // Registered transform type such as json-patch
+	Options map[string]interface{} // Type-specific settings
+}
+
+// UnmarshalJSON implements json.Unmarshaler for TransformRule
+func (t *TransformRule) UnmarshalJSON(data []byte) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	return t.fromFields(fields)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for TransformRule
+func (t *TransformRule) UnmarshalYAML(node *yaml.Node) error {
+	var fields map[string]interface{}
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	return t.fromFields(fields)
+}
+
+// UnmarshalTOML implements toml.Unmarshaler for TransformRule
+func (t *TransformRule) UnmarshalTOML(data interface{}) error {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("transform must be a table")
+	}
+	return t.fromFields(fields)
+}
+
+// fromFields sets the rule from the keys of a decoded transform entry
+func (t *TransformRule) fromFields(fields map[string]interface{}) error {
+	match, _ := fields["match"].(string)
+	kind, _ := fields["type"].(string)
+	if kind == "" {
+		return fmt.Errorf("transform is missing its type")
+	}
+
+	options := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if key != "match" && key != "type" {
+			options[key] = value
+		}
+	}
+
+	*t = TransformRule{Match: match, Type: kind, Options: options}
+	if t.Match == "" {
+		t.Match = "*"
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler for TransformRule
+func (t TransformRule) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(t.Options)+2)
+	for key, value := range t.Options {
+		fields[key] = value
+	}
+	fields["match"] = t.Match
+	fields["type"] = t.Type
+	return json.Marshal(fields)
+}
+
+// loadRulesFile reads the rules file at path into cfg
+// Sections present in the file replace the ones configured so far
+// Unknown fields and values of the wrong type are recorded in v, entry by
+// entry; files that can't be read or parsed at all are reported as errors
+func loadRulesFile(path string, cfg *Config, v *validator) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return fmt.Errorf("failed to parse rules file %s: %w", path, err)
+	}
+
+	var rules RulesFile
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		section := sections[name]
+		switch name {
+		case "routes":
+			rules.Routes = decodeRules[BackendRoute](section, name, v)
+		case "transforms":
+			rules.Transforms = decodeRules[TransformRule](section, name, v)
+		case "rewrites":
+			rules.Rewrites = decodeRules[RewriteRule](section, name, v)
+		default:
+			v.errorf(name, "unknown field")
+		}
+	}
+
+	if rules.Routes != nil {
+		cfg.Routes = rules.Routes
+	}
+	if rules.Transforms != nil {
+		cfg.Transforms = rules.Transforms
+	}
+	if rules.Rewrites != nil {
+		cfg.Rewrites = rules.Rewrites
+	}
+	return nil
+}
+
+// decodeRules decodes the entries of a rules file section one by one,
+// rejecting unknown fields, and records the problems of each entry in v
+func decodeRules[T any](data json.RawMessage, section string, v *validator) []T {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		v.errorf(section, "must be a list")
+		return nil
+	}
+	if entries == nil {
+		return nil
+	}
+
+	rules := make([]T, len(entries))
+	for i, entry := range entries {
+		dec := json.NewDecoder(bytes.NewReader(entry))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rules[i]); err != nil {
+			v.errors = append(v.errors, jsonFieldError(fmt.Sprintf("%s[%d]", section, i), entry, err))
+		}
+	}
+	return rules
+}
+
+// jsonFieldError converts an error decoding entry, the setting at path, into
+// a *FieldError naming the offending field where the error allows
+func jsonFieldError(path string, entry json.RawMessage, err error) *FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			path += "." + typeErr.Field
+		}
+		return &FieldError{Field: path, Err: fmt.Errorf("must be %s, not a JSON %s", typeErr.Type, typeErr.Value)}
+	}
+
+	// The decoder names an unknown field but not the object it is in; it
+	// belongs to the entry itself if the entry has it
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name, _ = strconv.Unquote(name)
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(entry, &fields) == nil {
+			if _, ok := fields[name]; ok {
+				return &FieldError{Field: path + "." + name, Err: fmt.Errorf("unknown field")}
+			}
+		}
+		return &FieldError{Field: path, Err: fmt.Errorf("unknown field %q", name)}
+	}
+	return &FieldError{Field: path, Err: err}
+}
+
+// prepareRules fills in the defaults of routes and rewrite rules read from a
+// file and expands the environment variables referenced by rewrite values
+func (c *Config) prepareRules() {
+	for i := range c.Routes {
+		if c.Routes[i].Match == "" {
+			c.Routes[i].Match = "*"
+		}
+		c.Routes[i].Backend = normalizeBackendURL(c.Routes[i].Backend)
+	}
+	for i := range c.Rewrites {
+		if c.Rewrites[i].Match == "" {
+			c.Rewrites[i].Match = "*"
+		}
+		c.Rewrites[i].Headers.expandEnv()
+		c.Rewrites[i].Query.expandEnv()
+	}
+}
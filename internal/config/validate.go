@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/chameleon/internal/hash"
+	"github.com/yourusername/chameleon/internal/jsonpath"
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/storage"
+	"github.com/yourusername/chameleon/internal/transform"
+)
+
+// FieldError is a problem with a single configuration setting
+type FieldError struct {
+	Field string // Path of the setting in the config file, e.g. hash.rules[1].strategy
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every invalid setting of a configuration
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return "invalid configuration: " + e.Errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d errors):", len(e.Errors))
+	for _, fe := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+// validator collects the problems found by Validate
+type validator struct {
+	errors []*FieldError
+}
+
+// errorf records a problem with field
+func (v *validator) errorf(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, &FieldError{Field: field, Err: fmt.Errorf(format, args...)})
+}
+
+// err returns the problems recorded so far as a *ValidationError, or nil
+func (v *validator) err() error {
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// check records err, if any, as a problem with field
+func (v *validator) check(field string, err error) {
+	if err != nil {
+		v.errors = append(v.errors, &FieldError{Field: field, Err: err})
+	}
+}
+
+// Validate validates the configuration
+// Every problem is reported, as a *ValidationError, with the path of the
+// setting in the config file; environment variables map to the same settings
+func (c *Config) Validate() error {
+	v := &validator{}
+	c.validate(v)
+	return v.err()
+}
+
+// validate records the problems of the configuration in v
+func (c *Config) validate(v *validator) {
+	if !c.Mode.Valid() {
+		v.errorf("mode", "unknown mode %q (must be record, replay, passthrough, record-missing, or replay-passthrough)", c.Mode)
+	}
+
+	if c.BackendURL == "" {
+		v.errorf("backend", "cannot be empty")
+	}
+
+	names := make(map[string]bool, len(c.Routes))
+	for i, rt := range c.Routes {
+		field := fmt.Sprintf("routes[%d]", i)
+		if !validNamespace(rt.Name) {
+			v.errorf(field+".name", "%q must be letters, digits, '.', '_' or '-'", rt.Name)
+		} else if names[rt.Name] {
+			v.errorf(field+".name", "duplicate route name %q", rt.Name)
+		}
+		names[rt.Name] = true
+		_, err := route.Parse(rt.Match)
+		v.check(field+".match", err)
+		if u, err := url.Parse(rt.Backend); err != nil || u.Host == "" {
+			v.errorf(field+".backend", "must be an absolute URL, got %q", rt.Backend)
+		}
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		v.errorf("port", "must be between 1 and 65535, got %d", c.Port)
+	}
+
+	if c.StoragePath == "" && c.Storage != storage.BackendMemory {
+		v.errorf("storage_path", "cannot be empty")
+	}
+	switch c.Storage {
+	case storage.BackendFile, storage.BackendMemory, storage.BackendArchive, storage.BackendBolt:
+	default:
+		v.errorf("storage_backend", "unknown value %q (must be file, memory, archive, or bolt)", c.Storage)
+	}
+	if c.Cassette != "" && !storage.ValidCassette(c.Cassette) {
+		v.errorf("cassette", "%q must be letters, digits, '.', '_' or '-'", c.Cassette)
+	}
+
+	c.Hash.validate(v)
+
+	if c.Fuzzy.Threshold < 0 || c.Fuzzy.Threshold > 1 {
+		v.errorf("fuzzy.threshold", "must be between 0 and 1, got %g", c.Fuzzy.Threshold)
+	}
+
+	if c.Stream.Speed < 0 {
+		v.errorf("stream.speed", "must be 0 or greater, got %g", c.Stream.Speed)
+	}
+
+	switch c.Sequence.End {
+	case SequenceEndLoop, SequenceEndLast, SequenceEndNotFound:
+	default:
+		v.errorf("sequence.end", "unknown value %q (must be loop, last, or 404)", c.Sequence.End)
+	}
+
+	switch c.WebSocket.Replay {
+	case WebSocketReplayTimed, WebSocketReplayTriggered:
+	default:
+		v.errorf("websocket.replay", "unknown value %q (must be timed or triggered)", c.WebSocket.Replay)
+	}
+
+	v.check("latency.delay", c.Latency.Delay.validate())
+	for i, rule := range c.Latency.Rules {
+		field := fmt.Sprintf("latency.rules[%d]", i)
+		_, err := route.Parse(rule.Match)
+		v.check(field+".match", err)
+		v.check(field+".delay", rule.Delay.validate())
+	}
+
+	for i, rule := range c.Faults {
+		rule.validate(v, fmt.Sprintf("faults[%d]", i))
+	}
+
+	for i, rule := range c.Transforms {
+		field := fmt.Sprintf("transforms[%d]", i)
+		_, err := route.Parse(rule.Match)
+		v.check(field+".match", err)
+		_, err = transform.New(rule.Type, rule.Options)
+		v.check(field, err)
+	}
+
+	for i, rule := range c.Rewrites {
+		field := fmt.Sprintf("rewrites[%d]", i)
+		_, err := route.Parse(rule.Match)
+		v.check(field+".match", err)
+		if rule.Path != nil {
+			if rule.Path.From == "" {
+				v.errorf(field+".path.from", "cannot be empty")
+			} else if rule.Path.Regex {
+				if _, err := regexp.Compile(rule.Path.From); err != nil {
+					v.errorf(field+".path.from", "invalid expression: %w", err)
+				}
+			}
+		}
+	}
+}
+
+// validate checks the hashing settings
+func (hc *HashConfig) validate(v *validator) {
+	strategy, err := hash.ParseStrategy(hc.Strategy)
+	v.check("hash.strategy", err)
+	if err == nil && strategy == hash.StrategyPathTemplate {
+		// A template comes from the route pattern of a rule
+		v.errorf("hash.strategy", "%s can only be used in hash.rules, which give the route template", strategy)
+	}
+
+	for i, rule := range hc.Rules {
+		field := fmt.Sprintf("hash.rules[%d]", i)
+		_, err := route.Parse(rule.Match)
+		v.check(field+".match", err)
+		strategy, err := hash.ParseStrategy(rule.Strategy)
+		v.check(field+".strategy", err)
+		if err == nil && strategy == hash.StrategyHeader && len(rule.Headers) == 0 &&
+			len(hc.Headers.Include) == 0 && len(hc.Headers.Secret) == 0 {
+			v.errorf(field+".headers", "%s uses the header strategy but no headers are configured", rule.Match)
+		}
+	}
+
+	switch hc.Query.Repeated {
+	case "preserve", "sort", "first", "last":
+	default:
+		v.errorf("hash.query.repeated", "unknown value %q (must be preserve, sort, first, or last)", hc.Query.Repeated)
+	}
+
+	if hc.Body.Mode != "raw" && hc.Body.Mode != "json" {
+		v.errorf("hash.body.mode", "unknown value %q (must be raw or json)", hc.Body.Mode)
+	}
+	for i, expr := range hc.Body.Ignore {
+		_, err := jsonpath.Parse(expr)
+		v.check(fmt.Sprintf("hash.body.ignore[%d]", i), err)
+	}
+}
+
+// validate checks a fault injection rule
+func (rule FaultRule) validate(v *validator, field string) {
+	_, err := route.Parse(rule.Match)
+	v.check(field+".match", err)
+
+	switch rule.Action {
+	case FaultStatus:
+		if rule.Status < 200 || rule.Status > 599 {
+			v.errorf(field+".status", "must be between 200 and 599, got %d", rule.Status)
+		}
+	case FaultTruncate:
+		if rule.Bytes < 0 {
+			v.errorf(field+".bytes", "cannot be negative, got %d", rule.Bytes)
+		}
+	case FaultDrop, FaultHang:
+	default:
+		v.errorf(field+".action", "unknown action %q (must be status, drop, truncate, or hang)", rule.Action)
+	}
+
+	if rule.Probability < 0 || rule.Probability > 1 {
+		v.errorf(field+".probability", "must be between 0 and 1, got %g", rule.Probability)
+	}
+}
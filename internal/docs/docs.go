@@ -0,0 +1,235 @@
+// Package docs renders recordings as browsable HTML API documentation
+package docs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// RecordedRequest represents a recorded API request/response for documentation
+type RecordedRequest struct {
+	Hash       string
+	Method     string
+	Path       string
+	StatusCode int
+	Headers    map[string][]string
+	Body       string
+	BodyType   string // "json", "html", "text", "binary"
+	Timestamp  time.Time
+	Request    *RequestDetails            // nil for recordings made before requests were stored
+	Messages   []storage.WebSocketMessage // Transcript of a WebSocket session
+}
+
+// RequestDetails describes the originating request of a recording
+type RequestDetails struct {
+	URL        string
+	Headers    map[string][]string
+	Body       string
+	BodyType   string
+	RemoteAddr string
+	Timestamp  time.Time
+	Duration   time.Duration
+}
+
+// pageData holds all data for the HTML template
+type pageData struct {
+	Title       string
+	GeneratedAt string
+	Requests    []RecordedRequest
+	TotalCount  int
+}
+
+// Generate renders the recordings in st to an HTML page at outputPath and
+// returns the number of recordings documented
+func Generate(st storage.Store, outputPath string) (int, error) {
+	requests, err := Load(st)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load recordings: %w", err)
+	}
+	if len(requests) == 0 {
+		return 0, fmt.Errorf("no recordings found")
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	if err := Render(file, requests); err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return len(requests), nil
+}
+
+// modTimer is implemented by stores that know when a recording was written,
+// which dates recordings made before requests were stored
+type modTimer interface {
+	ModTime(key string) (time.Time, error)
+}
+
+// Load reads every recording in st, including the ones in namespaces
+func Load(st storage.Store) ([]RecordedRequest, error) {
+	var requests []RecordedRequest
+
+	err := st.Iterate(func(key string, cached *storage.CachedResponse, err error) error {
+		if err != nil {
+			log.Printf("Warning: Failed to load %s: %v", key, err)
+			return nil
+		}
+
+		var timestamp time.Time
+		if mt, ok := st.(modTimer); ok {
+			timestamp, _ = mt.ModTime(key)
+		}
+		requests = append(requests, newRecordedRequest(key, cached, timestamp))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recordings: %w", err)
+	}
+
+	return requests, nil
+}
+
+// newRecordedRequest converts the recording stored under hash for display
+// timestamp is used when the recording doesn't say when it was made
+func newRecordedRequest(hash string, cached *storage.CachedResponse, timestamp time.Time) RecordedRequest {
+
+	// Process body
+	bodyStr, bodyType := formatBody(cached.Body)
+
+	var request *RequestDetails
+	if cached.Request != nil {
+		reqBody, reqBodyType := formatBody(storage.ResponseBody(cached.Request.Body))
+		request = &RequestDetails{
+			URL:        cached.Request.URL,
+			Headers:    cached.Request.Headers,
+			Body:       reqBody,
+			BodyType:   reqBodyType,
+			RemoteAddr: cached.Request.RemoteAddr,
+			Timestamp:  cached.Request.Timestamp,
+			Duration:   time.Duration(cached.Request.Duration),
+		}
+		// Prefer the recorded time over the file modification time
+		if !cached.Request.Timestamp.IsZero() {
+			timestamp = cached.Request.Timestamp
+		}
+	}
+
+	return RecordedRequest{
+		Hash:       hash,
+		Method:     cached.Method,
+		Path:       cached.Path,
+		StatusCode: cached.StatusCode,
+		Headers:    cached.Headers,
+		Body:       bodyStr,
+		BodyType:   bodyType,
+		Timestamp:  timestamp,
+		Request:    request,
+		Messages:   cached.WebSocket,
+	}
+}
+
+func formatBody(body storage.ResponseBody) (string, string) {
+	if len(body) == 0 {
+		return "", "empty"
+	}
+
+	// Try to parse as JSON first
+	var jsonValue interface{}
+	if err := json.Unmarshal(body, &jsonValue); err == nil {
+		// It's valid JSON, pretty print it
+		prettyJSON, err := json.MarshalIndent(jsonValue, "", "  ")
+		if err == nil {
+			return string(prettyJSON), "json"
+		}
+	}
+
+	// Check if it's HTML
+	bodyStr := string(body)
+	if strings.HasPrefix(strings.TrimSpace(bodyStr), "<") {
+		return bodyStr, "html"
+	}
+
+	// Check if it's base64 encoded
+	if decoded, err := base64.StdEncoding.DecodeString(bodyStr); err == nil && len(decoded) > 0 {
+		decodedStr := string(decoded)
+		// Try to detect decoded content type
+		if strings.HasPrefix(strings.TrimSpace(decodedStr), "<") {
+			return decodedStr, "html"
+		}
+		// Check if decoded is JSON
+		if json.Unmarshal(decoded, &jsonValue) == nil {
+			prettyJSON, err := json.MarshalIndent(jsonValue, "", "  ")
+			if err == nil {
+				return string(prettyJSON), "json"
+			}
+		}
+		return decodedStr, "text"
+	}
+
+	// Default to text
+	return bodyStr, "text"
+}
+
+// Render writes the documentation page for requests to w, sorted by method, then path
+func Render(w io.Writer, requests []RecordedRequest) error {
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].Method != requests[j].Method {
+			return requests[i].Method < requests[j].Method
+		}
+		return requests[i].Path < requests[j].Path
+	})
+
+	data := pageData{
+		Title:       "API Documentation",
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Requests:    requests,
+		TotalCount:  len(requests),
+	}
+
+	funcMap := template.FuncMap{
+		"lower":       strings.ToLower,
+		"statusClass": statusClass,
+	}
+
+	tmpl, err := template.New("docs").Funcs(funcMap).Parse(htmlTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return nil
+}
+
+func statusClass(statusCode int) string {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return "2xx"
+	case statusCode >= 300 && statusCode < 400:
+		return "3xx"
+	case statusCode >= 400 && statusCode < 500:
+		return "4xx"
+	case statusCode >= 500:
+		return "5xx"
+	default:
+		return "other"
+	}
+}
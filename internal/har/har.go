@@ -0,0 +1,452 @@
+// Package har converts between recordings and HAR 1.2 files, the HTTP
+// Archive format browsers' developer tools export
+// See http://www.softwareishard.com/blog/har-12-spec/
+package har
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// Version is the HAR version written by Export
+const Version = "1.2"
+
+// File is the top level of a HAR file
+type File struct {
+	Log Log `json:"log"`
+}
+
+// Log holds the recorded entries
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator names the application that wrote the file
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is an HTTP request and its response
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"` // Total time in milliseconds
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+
+	// WebSocketMessages is the transcript of a WebSocket session, in the
+	// format of Chrome's developer tools
+	WebSocketMessages []WebSocketMessage `json:"_webSocketMessages,omitempty"`
+}
+
+// Request describes a request
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"` // Absolute URL
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Response describes a response
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Cookie is a cookie sent or set; cookies are kept in the headers too, so
+// Chameleon ignores these
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NameValue is a header or query parameter
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is the body of a request
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is "base64" for bodies that aren't text; it is not part of
+	// HAR 1.2, which has no way to store binary request bodies
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+// Content is the body of a response, decoded from any Content-Encoding
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for bodies that aren't text
+}
+
+// Timings break the time of an entry down, in milliseconds
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// WebSocketMessage is a message of a WebSocket session
+type WebSocketMessage struct {
+	Type   string  `json:"type"`   // send or receive
+	Time   float64 `json:"time"`   // Unix time in seconds
+	Opcode int     `json:"opcode"` // 1 for text, 2 for binary
+	Data   string  `json:"data"`   // Text, or base64 for binary messages
+}
+
+// Export converts recordings to a HAR file, one entry per recorded response,
+// ordered by the time they were recorded
+// Recordings that don't say when they were made are dated now
+func Export(recordings []*storage.CachedResponse, creator Creator, now time.Time) *File {
+	var entries []Entry
+	for _, cached := range recordings {
+		for _, resp := range cached.Responses() {
+			entries = append(entries, newEntry(cached, resp, now))
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime < entries[j].StartedDateTime
+	})
+	return &File{Log: Log{Version: Version, Creator: creator, Entries: entries}}
+}
+
+// newEntry converts one response of a recording
+func newEntry(cached *storage.CachedResponse, resp storage.Response, now time.Time) Entry {
+	started := now
+	var total time.Duration
+	req := Request{
+		Method:      cached.Method,
+		URL:         cached.Path,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []Cookie{},
+		Headers:     []NameValue{},
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+	}
+	if r := cached.Request; r != nil {
+		if !r.Timestamp.IsZero() {
+			started = r.Timestamp
+		}
+		total = time.Duration(r.Duration)
+		req.URL = absoluteURL(r)
+		req.Headers = nameValues(r.Headers)
+		if u, err := url.Parse(r.URL); err == nil {
+			req.QueryString = queryString(u.Query())
+		}
+		if len(r.Body) > 0 {
+			text, encoding := encodeBody(r.Body)
+			req.PostData = &PostData{
+				MimeType: http.Header(r.Headers).Get("Content-Type"),
+				Text:     text,
+				Encoding: encoding,
+			}
+			req.BodySize = len(r.Body)
+		}
+	}
+
+	header := http.Header(resp.Headers)
+	body := decodeContent(resp.Body, header.Get("Content-Encoding"))
+	text, encoding := encodeBody(body)
+	out := Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []Cookie{},
+		Headers:     nameValues(resp.Headers),
+		Content: Content{
+			Size:     len(body),
+			MimeType: header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		},
+		RedirectURL: header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(resp.Body),
+	}
+
+	wait := time.Duration(resp.Latency)
+	if total < wait {
+		total = wait
+	}
+	entry := Entry{
+		StartedDateTime: started.UTC().Format(time.RFC3339Nano),
+		Time:            milliseconds(total),
+		Request:         req,
+		Response:        out,
+		Timings:         Timings{Send: 0, Wait: milliseconds(wait), Receive: milliseconds(total - wait)},
+	}
+	for _, msg := range resp.WebSocket {
+		if m, ok := exportMessage(msg, started); ok {
+			entry.WebSocketMessages = append(entry.WebSocketMessages, m)
+		}
+	}
+	return entry
+}
+
+// absoluteURL returns the URL a recorded request was sent to
+func absoluteURL(r *storage.CachedRequest) string {
+	base := r.Backend
+	if base == "" {
+		host := r.Host
+		if host == "" {
+			host = "localhost"
+		}
+		base = "http://" + host
+	}
+	return strings.TrimSuffix(base, "/") + r.URL
+}
+
+// exportMessage converts a recorded WebSocket message; close frames, which
+// HAR has no place for, are reported with ok == false
+func exportMessage(msg storage.WebSocketMessage, upgraded time.Time) (WebSocketMessage, bool) {
+	m := WebSocketMessage{
+		Type: "receive",
+		Time: float64(upgraded.Add(time.Duration(msg.Offset)).UnixNano()) / 1e9,
+	}
+	if msg.From == storage.FromClient {
+		m.Type = "send"
+	}
+	switch msg.Type {
+	case "text":
+		m.Opcode, m.Data = 1, string(msg.Bytes())
+	case "binary":
+		m.Opcode, m.Data = 2, base64.StdEncoding.EncodeToString(msg.Bytes())
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// Recording converts an entry into a recording
+// Headers that describe the encoding of the original body are dropped, since
+// HAR files hold decoded bodies
+func (e *Entry) Recording() (*storage.CachedResponse, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid request URL %q", e.Request.URL)
+	}
+	scheme := u.Scheme
+	switch scheme {
+	case "http", "https":
+	case "ws", "wss":
+		// WebSocket sessions start with an HTTP request to the same server
+		scheme = strings.Replace(scheme, "ws", "http", 1)
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if e.Response.Status == 0 {
+		return nil, fmt.Errorf("request has no response")
+	}
+
+	started, err := time.Parse(time.RFC3339Nano, e.StartedDateTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid startedDateTime %q", e.StartedDateTime)
+	}
+
+	reqBody, err := decodeBody(postDataText(e.Request.PostData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	body, err := decodeBody(e.Response.Content.Text, e.Response.Content.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response body: %w", err)
+	}
+
+	headers := headerMap(e.Response.Headers)
+	for _, name := range []string{"Content-Encoding", "Content-Length", "Transfer-Encoding"} {
+		delete(headers, name)
+	}
+
+	cached := &storage.CachedResponse{
+		Method: strings.ToUpper(e.Request.Method),
+		Path:   u.Path, // Decoded, as the proxy records it
+		Response: storage.Response{
+			StatusCode: e.Response.Status,
+			Headers:    headers,
+			Body:       body,
+			Latency:    storage.Duration(fromMilliseconds(e.Timings.Wait)),
+		},
+		Request: &storage.CachedRequest{
+			URL:       u.RequestURI(),
+			Host:      u.Host,
+			Backend:   scheme + "://" + u.Host,
+			Headers:   headerMap(e.Request.Headers),
+			Body:      reqBody,
+			Timestamp: started,
+			Duration:  storage.Duration(fromMilliseconds(e.Time)),
+		},
+	}
+	if cached.Path == "" {
+		cached.Path = "/"
+	}
+	for _, m := range e.WebSocketMessages {
+		msg, err := importMessage(m, started)
+		if err != nil {
+			return nil, err
+		}
+		cached.WebSocket = append(cached.WebSocket, msg)
+	}
+	return cached, nil
+}
+
+// importMessage converts a WebSocket message of an entry started at upgraded
+func importMessage(m WebSocketMessage, upgraded time.Time) (storage.WebSocketMessage, error) {
+	from := storage.FromServer
+	if m.Type == "send" {
+		from = storage.FromClient
+	}
+	sec, frac := math.Modf(m.Time)
+	// Float seconds lose precision below a microsecond
+	offset := time.Unix(int64(sec), int64(frac*1e9)).Sub(upgraded).Round(time.Microsecond)
+	if offset < 0 {
+		offset = 0
+	}
+
+	switch m.Opcode {
+	case 1:
+		return storage.NewWebSocketMessage(from, offset, "text", []byte(m.Data)), nil
+	case 2:
+		data, err := base64.StdEncoding.DecodeString(m.Data)
+		if err != nil {
+			return storage.WebSocketMessage{}, fmt.Errorf("invalid binary WebSocket message: %w", err)
+		}
+		return storage.NewWebSocketMessage(from, offset, "binary", data), nil
+	default:
+		return storage.WebSocketMessage{}, fmt.Errorf("unsupported WebSocket opcode %d", m.Opcode)
+	}
+}
+
+// postDataText returns the body text of a request and its encoding
+func postDataText(p *PostData) (string, string) {
+	if p == nil {
+		return "", ""
+	}
+	return p.Text, p.Encoding
+}
+
+// encodeBody returns body as HAR text, base64-encoded if it isn't valid UTF-8
+func encodeBody(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeBody reverses encodeBody
+func decodeBody(text, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		if text == "" {
+			return nil, nil
+		}
+		return []byte(text), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(text)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// decodeContent returns a recorded body without its Content-Encoding, since
+// HAR files hold decoded content. Bodies that can't be decoded are returned
+// as they are
+func decodeContent(body []byte, encoding string) []byte {
+	if !strings.EqualFold(encoding, "gzip") {
+		return body
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
+
+// nameValues converts headers to HAR name/value pairs, sorted by name
+func nameValues(headers map[string][]string) []NameValue {
+	pairs := []NameValue{}
+	for name, values := range headers {
+		for _, value := range values {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+// queryString converts query parameters to HAR name/value pairs, sorted by name
+func queryString(query url.Values) []NameValue {
+	pairs := []NameValue{}
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+// headerMap converts HAR headers to a header map
+// HTTP/2 pseudo-headers such as :authority are dropped
+func headerMap(pairs []NameValue) map[string][]string {
+	header := make(http.Header, len(pairs))
+	for _, pair := range pairs {
+		if strings.HasPrefix(pair.Name, ":") {
+			continue
+		}
+		header.Add(pair.Name, pair.Value)
+	}
+	return header
+}
+
+// milliseconds converts d to HAR milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// fromMilliseconds converts HAR milliseconds, where -1 means unknown, to a duration
+func fromMilliseconds(ms float64) time.Duration {
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
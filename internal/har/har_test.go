@@ -0,0 +1,245 @@
+package har
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+var recordedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// roundTrip exports recordings to a HAR file, encodes and decodes it, and
+// converts every entry back into a recording
+func roundTrip(t *testing.T, recordings ...*storage.CachedResponse) []*storage.CachedResponse {
+	t.Helper()
+	data, err := json.Marshal(Export(recordings, Creator{Name: "test", Version: "1"}, recordedAt))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	var out []*storage.CachedResponse
+	for i := range file.Log.Entries {
+		cached, err := file.Log.Entries[i].Recording()
+		if err != nil {
+			t.Fatalf("entry %d: Recording: %v", i, err)
+		}
+		out = append(out, cached)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := &storage.CachedResponse{
+		Method: "POST",
+		Path:   "/api/upload",
+		Response: storage.Response{
+			StatusCode: 201,
+			Headers:    map[string][]string{"Content-Type": {"application/json"}, "X-Id": {"1", "2"}},
+			Body:       storage.ResponseBody(`{"ok":true}`),
+			Latency:    storage.Duration(40 * time.Millisecond),
+		},
+		Request: &storage.CachedRequest{
+			URL:       "/api/upload?tag=a&tag=b",
+			Host:      "api.example.test",
+			Backend:   "https://api.example.test",
+			Headers:   map[string][]string{"Content-Type": {"application/octet-stream"}},
+			Body:      storage.RequestBody{0x00, 0xff, 0x10, 0x80},
+			Timestamp: recordedAt.Add(-time.Hour),
+			Duration:  storage.Duration(55 * time.Millisecond),
+		},
+	}
+
+	got := roundTrip(t, want)
+	if len(got) != 1 {
+		t.Fatalf("got %d recordings, want 1", len(got))
+	}
+	cached := got[0]
+	if cached.Method != want.Method || cached.Path != want.Path || cached.StatusCode != want.StatusCode {
+		t.Errorf("got %s %s %d, want %s %s %d", cached.Method, cached.Path, cached.StatusCode, want.Method, want.Path, want.StatusCode)
+	}
+	if !reflect.DeepEqual(cached.Headers, want.Headers) {
+		t.Errorf("response headers = %v, want %v", cached.Headers, want.Headers)
+	}
+	if !bytes.Equal(cached.Body, want.Body) {
+		t.Errorf("response body = %q, want %q", cached.Body, want.Body)
+	}
+	if cached.Latency != want.Latency {
+		t.Errorf("latency = %v, want %v", cached.Latency, want.Latency)
+	}
+
+	req := cached.Request
+	if req.URL != want.Request.URL || req.Host != want.Request.Host || req.Backend != want.Request.Backend {
+		t.Errorf("request = %s %s %s, want %s %s %s", req.Backend, req.Host, req.URL, want.Request.Backend, want.Request.Host, want.Request.URL)
+	}
+	if !reflect.DeepEqual(req.Headers, want.Request.Headers) {
+		t.Errorf("request headers = %v, want %v", req.Headers, want.Request.Headers)
+	}
+	if !bytes.Equal(req.Body, want.Request.Body) {
+		t.Errorf("request body = %x, want %x", []byte(req.Body), []byte(want.Request.Body))
+	}
+	if !req.Timestamp.Equal(want.Request.Timestamp) || req.Duration != want.Request.Duration {
+		t.Errorf("request at %v for %v, want %v for %v", req.Timestamp, req.Duration, want.Request.Timestamp, want.Request.Duration)
+	}
+}
+
+func TestRoundTripGzipBody(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("plain text"))
+	zw.Close()
+
+	got := roundTrip(t, &storage.CachedResponse{
+		Method: "GET",
+		Path:   "/",
+		Response: storage.Response{
+			StatusCode: 200,
+			Headers:    map[string][]string{"Content-Encoding": {"gzip"}, "Content-Length": {"30"}, "Content-Type": {"text/plain"}},
+			Body:       compressed.Bytes(),
+		},
+		Request: &storage.CachedRequest{URL: "/", Host: "example.test", Timestamp: recordedAt},
+	})
+
+	// HAR files hold decoded bodies, so the encoding headers don't apply anymore
+	if string(got[0].Body) != "plain text" {
+		t.Errorf("body = %q, want the decoded body", got[0].Body)
+	}
+	want := map[string][]string{"Content-Type": {"text/plain"}}
+	if !reflect.DeepEqual(got[0].Headers, want) {
+		t.Errorf("headers = %v, want %v", got[0].Headers, want)
+	}
+}
+
+func TestRoundTripEncodedPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string // Decoded, as the proxy records it
+		url  string
+	}{
+		{"space and unicode", "/files/a b/ü", "/files/a%20b/%C3%BC?v=1"},
+		{"encoded slash", "/files/a/b", "/files/a%2Fb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &storage.CachedResponse{
+				Method:   "GET",
+				Path:     tt.path,
+				Response: storage.Response{StatusCode: 200},
+				Request:  &storage.CachedRequest{URL: tt.url, Host: "example.test", Timestamp: recordedAt},
+			}
+
+			got := roundTrip(t, want)[0]
+			if got.Path != want.Path {
+				t.Errorf("path = %q, want %q", got.Path, want.Path)
+			}
+			if got.Request.URL != tt.url {
+				t.Errorf("request URL = %q, want %q", got.Request.URL, tt.url)
+			}
+		})
+	}
+}
+
+func TestRoundTripSequence(t *testing.T) {
+	cached := &storage.CachedResponse{
+		Method:   "GET",
+		Path:     "/api/job",
+		Response: storage.Response{StatusCode: 202, Body: storage.ResponseBody("pending")},
+		Request:  &storage.CachedRequest{URL: "/api/job", Host: "example.test", Timestamp: recordedAt},
+	}
+	cached.Append(storage.Response{StatusCode: 200, Body: storage.ResponseBody("done")})
+
+	got := roundTrip(t, cached)
+	if len(got) != 2 {
+		t.Fatalf("got %d recordings, want one per response", len(got))
+	}
+	for i, status := range []int{202, 200} {
+		if got[i].StatusCode != status {
+			t.Errorf("recording %d has status %d, want %d", i, got[i].StatusCode, status)
+		}
+	}
+}
+
+func TestRoundTripWebSocket(t *testing.T) {
+	messages := []storage.WebSocketMessage{
+		storage.NewWebSocketMessage(storage.FromClient, 10*time.Millisecond, "text", []byte("subscribe")),
+		storage.NewWebSocketMessage(storage.FromServer, 25*time.Millisecond, "binary", []byte{0xde, 0xad}),
+		storage.NewWebSocketMessage(storage.FromServer, 30*time.Millisecond, "close", []byte{0x03, 0xe8}),
+	}
+	got := roundTrip(t, &storage.CachedResponse{
+		Method:   "GET",
+		Path:     "/ws",
+		Response: storage.Response{StatusCode: 101, WebSocket: messages},
+		Request:  &storage.CachedRequest{URL: "/ws", Host: "example.test", Timestamp: recordedAt},
+	})
+
+	// HAR has no place for close frames
+	want := messages[:2]
+	if len(got[0].WebSocket) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got[0].WebSocket), len(want))
+	}
+	for i, msg := range got[0].WebSocket {
+		if msg.From != want[i].From || msg.Type != want[i].Type || msg.Offset != want[i].Offset || !bytes.Equal(msg.Bytes(), want[i].Bytes()) {
+			t.Errorf("message %d = %+v, want %+v", i, msg, want[i])
+		}
+	}
+}
+
+func TestRecordingErrors(t *testing.T) {
+	valid := func() Entry {
+		return Entry{
+			StartedDateTime: "2024-05-01T12:00:00Z",
+			Request:         Request{Method: "GET", URL: "https://example.test/api"},
+			Response:        Response{Status: 200},
+		}
+	}
+	tests := []struct {
+		name  string
+		edit  func(e *Entry)
+		error string
+	}{
+		{"relative URL", func(e *Entry) { e.Request.URL = "/api" }, "invalid request URL"},
+		{"unsupported scheme", func(e *Entry) { e.Request.URL = "ftp://example.test/file" }, "unsupported URL scheme"},
+		{"no response", func(e *Entry) { e.Response.Status = 0 }, "no response"},
+		{"bad date", func(e *Entry) { e.StartedDateTime = "yesterday" }, "invalid startedDateTime"},
+		{"bad request body", func(e *Entry) { e.Request.PostData = &PostData{Text: "%%%", Encoding: "base64"} }, "invalid request body"},
+		{"bad response encoding", func(e *Entry) { e.Response.Content = Content{Text: "x", Encoding: "rot13"} }, "invalid response body"},
+		{"bad WebSocket opcode", func(e *Entry) { e.WebSocketMessages = []WebSocketMessage{{Type: "send", Opcode: 9}} }, "unsupported WebSocket opcode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := valid()
+			if _, err := entry.Recording(); err != nil {
+				t.Fatalf("valid entry: %v", err)
+			}
+			tt.edit(&entry)
+			_, err := entry.Recording()
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Recording = %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestRecordingWebSocketURL(t *testing.T) {
+	entry := Entry{
+		StartedDateTime: "2024-05-01T12:00:00Z",
+		Request:         Request{Method: "get", URL: "wss://example.test"},
+		Response:        Response{Status: 101},
+	}
+	cached, err := entry.Recording()
+	if err != nil {
+		t.Fatalf("Recording: %v", err)
+	}
+	if cached.Method != "GET" || cached.Path != "/" || cached.Request.Backend != "https://example.test" {
+		t.Errorf("got %s %s via %s, want GET / via https://example.test", cached.Method, cached.Path, cached.Request.Backend)
+	}
+}
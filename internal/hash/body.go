@@ -0,0 +1,67 @@
+package hash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/yourusername/chameleon/internal/jsonpath"
+)
+
+// BodyMode controls how the request body is turned into key material
+type BodyMode string
+
+const (
+	// BodyRaw hashes the body bytes exactly as received
+	BodyRaw BodyMode = "raw"
+	// BodyJSON hashes a canonical form of JSON bodies (sorted keys, no
+	// insignificant whitespace); bodies that are not valid JSON are hashed raw
+	BodyJSON BodyMode = "json"
+)
+
+// BodyOptions controls how the request body participates in the hash
+type BodyOptions struct {
+	Mode BodyMode
+	// Ignore lists JSON fields removed before hashing in JSON mode, such as
+	// generated request IDs, timestamps and nonces
+	Ignore []*jsonpath.Path
+}
+
+// CanonicalBody returns the key material for body according to opts
+func CanonicalBody(body []byte, opts BodyOptions) []byte {
+	if opts.Mode != BodyJSON || len(body) == 0 {
+		return body
+	}
+
+	canonical, err := CanonicalJSON(body, opts.Ignore)
+	if err != nil {
+		// Not JSON - fall back to the raw bytes
+		return body
+	}
+	return canonical
+}
+
+// CanonicalJSON re-encodes a JSON document with sorted object keys and no
+// insignificant whitespace, after removing the fields matched by ignore.
+// Numbers keep their original literal form to avoid precision loss
+func CanonicalJSON(data []byte, ignore []*jsonpath.Path) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	// Reject trailing data such as `{"a":1} garbage`
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	for _, path := range ignore {
+		doc = path.Delete(doc)
+	}
+
+	// encoding/json writes map keys in sorted order
+	return json.Marshal(doc)
+}
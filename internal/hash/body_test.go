@@ -0,0 +1,32 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/jsonpath"
+)
+
+func TestCanonicalBody(t *testing.T) {
+	ignore := []*jsonpath.Path{jsonpath.MustParse("$.requestId"), jsonpath.MustParse("$..ts")}
+	tests := []struct {
+		name string
+		body string
+		opts BodyOptions
+		want string
+	}{
+		{"raw mode", `{"b": 2, "a": 1}`, BodyOptions{Mode: BodyRaw}, `{"b": 2, "a": 1}`},
+		{"sorted keys", `{"b": 2, "a": {"d": [1, 2], "c": null}}`, BodyOptions{Mode: BodyJSON}, `{"a":{"c":null,"d":[1,2]},"b":2}`},
+		{"numbers keep their form", `{"n": 1.50, "big": 12345678901234567890}`, BodyOptions{Mode: BodyJSON}, `{"big":12345678901234567890,"n":1.50}`},
+		{"ignored fields", `{"q": "x", "requestId": "7", "meta": {"ts": 1}}`, BodyOptions{Mode: BodyJSON, Ignore: ignore}, `{"meta":{},"q":"x"}`},
+		{"not JSON", `q=x&y=z`, BodyOptions{Mode: BodyJSON}, `q=x&y=z`},
+		{"trailing data", `{"a": 1} garbage`, BodyOptions{Mode: BodyJSON}, `{"a": 1} garbage`},
+		{"empty", ``, BodyOptions{Mode: BodyJSON}, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(CanonicalBody([]byte(tt.body), tt.opts)); got != tt.want {
+				t.Errorf("CanonicalBody(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
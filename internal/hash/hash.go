@@ -4,27 +4,52 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"io"
+	"net/http"
 )
 
-// Generate creates a SHA256 hash from HTTP method, URL path, and request body
+// Options controls which parts of a request participate in the hash
+type Options struct {
+	Query   QueryOptions
+	Headers HeaderOptions
+	Body    BodyOptions
+}
+
+// Generate creates a SHA256 hash from HTTP method, URL path, query string, selected headers, and request body
 // Returns a hex-encoded string suitable for use as a filename
-func Generate(method, path string, body io.Reader) (string, error) {
+func Generate(r *http.Request, body []byte, opts Options) (string, error) {
 	h := sha256.New()
 
 	// Include method and path in the hash
-	if _, err := fmt.Fprintf(h, "%s:%s:", method, path); err != nil {
+	if _, err := fmt.Fprintf(h, "%s:%s:", r.Method, r.URL.Path); err != nil {
 		return "", fmt.Errorf("failed to write method and path to hash: %w", err)
 	}
 
+	// Include the normalized query string if enabled
+	// Requests without a query keep the same hash they had before queries were hashed
+	if opts.Query.Enabled {
+		if query := NormalizeQuery(r.URL.RawQuery, opts.Query); query != "" {
+			if _, err := fmt.Fprintf(h, "?%s:", query); err != nil {
+				return "", fmt.Errorf("failed to write query to hash: %w", err)
+			}
+		}
+	}
+
+	// Include the selected request headers
+	if opts.Headers.Enabled() {
+		for _, line := range CanonicalHeaders(r, opts.Headers) {
+			if _, err := fmt.Fprintf(h, "%s\n", line); err != nil {
+				return "", fmt.Errorf("failed to write headers to hash: %w", err)
+			}
+		}
+	}
+
 	// Include request body in the hash if present
-	if body != nil {
-		if _, err := io.Copy(h, body); err != nil {
-			return "", fmt.Errorf("failed to read request body for hashing: %w", err)
+	if len(body) > 0 {
+		if _, err := h.Write(CanonicalBody(body, opts.Body)); err != nil {
+			return "", fmt.Errorf("failed to write request body to hash: %w", err)
 		}
 	}
 
 	// Return hex-encoded hash
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
-
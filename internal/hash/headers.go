@@ -0,0 +1,103 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// digestPrefix marks a header value that was replaced by its SHA256 digest
+const digestPrefix = "sha256:"
+
+// CredentialHeaders are always treated as secret, whether or not they are
+// configured as such, so credentials never end up in recordings
+var CredentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// HeaderOptions controls which request headers participate in the hash
+type HeaderOptions struct {
+	// Include lists headers whose values are part of the hash
+	Include []string
+	// Secret lists headers (such as Authorization) whose values are digested
+	// before use, so the raw secret never appears in key material.
+	// Secret headers are included in the hash even if missing from Include
+	Secret []string
+}
+
+// Enabled reports whether any header participates in the hash
+func (o HeaderOptions) Enabled() bool {
+	return len(o.Include) > 0 || len(o.Secret) > 0
+}
+
+// IsSecret reports whether the named header is a credential header or is
+// configured as a secret
+func (o HeaderOptions) IsSecret(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, credential := range CredentialHeaders {
+		if credential == name {
+			return true
+		}
+	}
+	for _, secret := range o.Secret {
+		if http.CanonicalHeaderKey(secret) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalHeaders returns the "Name: value" lines that header hashing uses
+// for r, sorted by header name. Missing headers produce an empty value so a
+// request without a header never shares a key with one that has it
+func CanonicalHeaders(r *http.Request, opts HeaderOptions) []string {
+	names := make(map[string]bool)
+	for _, name := range opts.Include {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range opts.Secret {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+
+	lines := make([]string, 0, len(names))
+	for name := range names {
+		value := joinValues(r.Header.Values(name))
+		// Values stored in digest form (e.g. in a recorded request) are used as-is,
+		// so recordings can be hashed again
+		if value != "" && opts.IsSecret(name) && !strings.HasPrefix(value, digestPrefix) {
+			value = Digest(value)
+		}
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+
+	return lines
+}
+
+// RedactHeaders returns a copy of header with the values of secret headers
+// replaced by their digest, suitable for storing alongside a recording
+func RedactHeaders(header http.Header, opts HeaderOptions) map[string][]string {
+	redacted := make(map[string][]string, len(header))
+	for name, values := range header {
+		if opts.IsSecret(name) {
+			values = []string{Digest(joinValues(values))}
+		}
+		redacted[name] = append([]string(nil), values...)
+	}
+	return redacted
+}
+
+// joinValues combines the values of a header into a single comma-separated string
+func joinValues(values []string) string {
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// Digest returns the SHA256 digest of a secret value in "sha256:<hex>" form
+func Digest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package hash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{
+		"Authorization": {"Bearer secret"},
+		"Cookie":        {"session=1", "theme=dark"},
+		"X-Api-Key":     {"key"},
+		"Accept":        {"text/html", "application/json"},
+	}
+	redacted := RedactHeaders(header, HeaderOptions{Secret: []string{"x-api-key"}})
+
+	want := map[string][]string{
+		"Authorization": {Digest("Bearer secret")},
+		"Cookie":        {Digest("session=1,theme=dark")},
+		"X-Api-Key":     {Digest("key")},
+		"Accept":        {"text/html", "application/json"},
+	}
+	if !reflect.DeepEqual(redacted, want) {
+		t.Errorf("RedactHeaders = %v, want %v", redacted, want)
+	}
+	// The original header is left alone
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("RedactHeaders changed its argument")
+	}
+}
+
+func TestCanonicalHeadersOfRedactedRequest(t *testing.T) {
+	opts := HeaderOptions{Include: []string{"Accept"}, Secret: []string{"X-Api-Key"}}
+
+	sent := httptest.NewRequest("GET", "/", nil)
+	sent.Header.Set("Accept", "text/html")
+	sent.Header.Set("X-Api-Key", "key")
+
+	// A recorded request holds the digests, which must hash like the original values
+	stored := httptest.NewRequest("GET", "/", nil)
+	for name, values := range RedactHeaders(sent.Header, opts) {
+		stored.Header[name] = values
+	}
+
+	want := []string{"Accept: text/html", "X-Api-Key: " + Digest("key")}
+	for _, r := range []*http.Request{sent, stored} {
+		if got := CanonicalHeaders(r, opts); !reflect.DeepEqual(got, want) {
+			t.Errorf("CanonicalHeaders = %v, want %v", got, want)
+		}
+	}
+}
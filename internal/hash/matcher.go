@@ -0,0 +1,160 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/yourusername/chameleon/internal/route"
+)
+
+// Matcher maps a request to the key its recording is stored under
+// Requests that produce the same key share a recording
+type Matcher interface {
+	// Key returns the key for r; body is the already-read request body
+	Key(r *http.Request, body []byte) (string, error)
+}
+
+// Strategy names a built-in matching strategy
+type Strategy string
+
+const (
+	// StrategyDefault hashes method, path, query, headers and body using the configured options
+	StrategyDefault Strategy = "default"
+	// StrategyExact hashes method, path, raw query and raw body with no normalization at all
+	StrategyExact Strategy = "exact"
+	// StrategyPathTemplate hashes the route template (/api/users/{id}) instead of
+	// the concrete path, so every request matching the template shares a recording
+	StrategyPathTemplate Strategy = "path-template"
+	// StrategyJSON is the default strategy with JSON body canonicalization forced on
+	StrategyJSON Strategy = "json"
+	// StrategyHeader is the default strategy with a route-specific set of hashed headers
+	StrategyHeader Strategy = "header"
+)
+
+// ParseStrategy validates a strategy name
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(name); s {
+	case StrategyDefault, StrategyExact, StrategyPathTemplate, StrategyJSON, StrategyHeader:
+		return s, nil
+	case "":
+		return StrategyDefault, nil
+	default:
+		return "", fmt.Errorf("unknown matching strategy: %s (must be default, exact, path-template, json, or header)", name)
+	}
+}
+
+// MatcherFunc adapts an ordinary function to the Matcher interface
+type MatcherFunc func(r *http.Request, body []byte) (string, error)
+
+// Key calls f(r, body)
+func (f MatcherFunc) Key(r *http.Request, body []byte) (string, error) {
+	return f(r, body)
+}
+
+// OptionsMatcher hashes requests with Generate using fixed options
+type OptionsMatcher struct {
+	Options Options
+	// Template replaces the request path in the hash when set
+	Template string
+}
+
+// Key implements Matcher
+func (m *OptionsMatcher) Key(r *http.Request, body []byte) (string, error) {
+	if m.Template == "" {
+		return Generate(r, body, m.Options)
+	}
+
+	// Hash a shallow copy whose path is the template
+	u := *r.URL
+	u.Path = m.Template
+	return Generate(requestWithURL(r, &u), body, m.Options)
+}
+
+// ExactMatcher hashes the request exactly as received
+type ExactMatcher struct{}
+
+// Key implements Matcher
+func (ExactMatcher) Key(r *http.Request, body []byte) (string, error) {
+	h := sha256.New()
+	if _, err := fmt.Fprintf(h, "%s:%s:?%s:", r.Method, r.URL.Path, r.URL.RawQuery); err != nil {
+		return "", fmt.Errorf("failed to write request line to hash: %w", err)
+	}
+	if _, err := h.Write(body); err != nil {
+		return "", fmt.Errorf("failed to write request body to hash: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// NewMatcher builds the matcher for a built-in strategy
+// opts are the configured hashing options; pattern is the route the strategy
+// is attached to (required for path-template) and headers overrides the
+// hashed headers for the header strategy
+func NewMatcher(strategy Strategy, opts Options, pattern *route.Pattern, headers []string) (Matcher, error) {
+	switch strategy {
+	case StrategyDefault, "":
+		return &OptionsMatcher{Options: opts}, nil
+
+	case StrategyExact:
+		return ExactMatcher{}, nil
+
+	case StrategyPathTemplate:
+		if pattern == nil {
+			return nil, fmt.Errorf("%s strategy requires a route pattern", strategy)
+		}
+		return &OptionsMatcher{Options: opts, Template: pattern.Template()}, nil
+
+	case StrategyJSON:
+		opts.Body.Mode = BodyJSON
+		return &OptionsMatcher{Options: opts}, nil
+
+	case StrategyHeader:
+		if len(headers) > 0 {
+			opts.Headers.Include = headers
+		}
+		if !opts.Headers.Enabled() {
+			return nil, fmt.Errorf("%s strategy requires at least one header", strategy)
+		}
+		return &OptionsMatcher{Options: opts}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown matching strategy: %s", strategy)
+	}
+}
+
+// Route attaches a matcher to the requests matching a pattern
+type Route struct {
+	Pattern *route.Pattern
+	Matcher Matcher
+}
+
+// Router is a Matcher that delegates to the first route matching the request,
+// or to Fallback if none does
+type Router struct {
+	Routes   []Route
+	Fallback Matcher
+}
+
+// Key implements Matcher
+func (rt *Router) Key(r *http.Request, body []byte) (string, error) {
+	return rt.MatcherFor(r).Key(r, body)
+}
+
+// MatcherFor returns the matcher that handles r
+func (rt *Router) MatcherFor(r *http.Request) Matcher {
+	for _, rr := range rt.Routes {
+		if rr.Pattern.MatchRequest(r) {
+			return rr.Matcher
+		}
+	}
+	return rt.Fallback
+}
+
+// requestWithURL returns a shallow copy of r pointing at u
+func requestWithURL(r *http.Request, u *url.URL) *http.Request {
+	copied := *r
+	copied.URL = u
+	return &copied
+}
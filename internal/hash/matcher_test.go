@@ -0,0 +1,264 @@
+package hash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/jsonpath"
+	"github.com/yourusername/chameleon/internal/route"
+)
+
+// request is a request to hash along with its body
+type request struct {
+	method, target, body string
+	headers              map[string]string
+}
+
+func (req request) key(t *testing.T, m Matcher) string {
+	t.Helper()
+	r := httptest.NewRequest(req.method, req.target, strings.NewReader(req.body))
+	for name, value := range req.headers {
+		r.Header.Set(name, value)
+	}
+	key, err := m.Key(r, []byte(req.body))
+	if err != nil {
+		t.Fatalf("Key(%s %s): %v", req.method, req.target, err)
+	}
+	return key
+}
+
+func TestMatcherStrategies(t *testing.T) {
+	queryOpts := Options{Query: QueryOptions{Enabled: true, Sort: true, Ignore: []string{"_"}}}
+	users := route.MustParse("GET /api/users/{id}")
+
+	tests := []struct {
+		name     string
+		strategy Strategy
+		opts     Options
+		pattern  *route.Pattern
+		headers  []string
+		a, b     request
+		same     bool
+	}{
+		{
+			name:     "default ignores the query unless enabled",
+			strategy: StrategyDefault,
+			a:        request{method: "GET", target: "/api/users?page=1"},
+			b:        request{method: "GET", target: "/api/users?page=2"},
+			same:     true,
+		},
+		{
+			name:     "default with queries enabled",
+			strategy: StrategyDefault,
+			opts:     queryOpts,
+			a:        request{method: "GET", target: "/api/users?page=1"},
+			b:        request{method: "GET", target: "/api/users?page=2"},
+		},
+		{
+			name:     "default normalizes the query",
+			strategy: StrategyDefault,
+			opts:     queryOpts,
+			a:        request{method: "GET", target: "/api/users?page=1&sort=name&_=1699999"},
+			b:        request{method: "GET", target: "/api/users?_=1700000&sort=name&page=%31"},
+			same:     true,
+		},
+		{
+			name:     "default separates methods",
+			strategy: StrategyDefault,
+			a:        request{method: "GET", target: "/api/users"},
+			b:        request{method: "DELETE", target: "/api/users"},
+		},
+		{
+			name:     "default hashes bodies raw",
+			strategy: StrategyDefault,
+			a:        request{method: "POST", target: "/api/search", body: `{"a":1,"b":2}`},
+			b:        request{method: "POST", target: "/api/search", body: `{"b": 2, "a": 1}`},
+		},
+		{
+			name:     "exact keeps the query as sent",
+			strategy: StrategyExact,
+			opts:     queryOpts,
+			a:        request{method: "GET", target: "/api/users?a=1&b=2"},
+			b:        request{method: "GET", target: "/api/users?b=2&a=1"},
+		},
+		{
+			name:     "exact ignores headers",
+			strategy: StrategyExact,
+			opts:     Options{Headers: HeaderOptions{Include: []string{"Accept"}}},
+			a:        request{method: "GET", target: "/", headers: map[string]string{"Accept": "text/html"}},
+			b:        request{method: "GET", target: "/", headers: map[string]string{"Accept": "application/json"}},
+			same:     true,
+		},
+		{
+			name:     "path-template shares a recording across the template",
+			strategy: StrategyPathTemplate,
+			pattern:  users,
+			a:        request{method: "GET", target: "/api/users/1"},
+			b:        request{method: "GET", target: "/api/users/2"},
+			same:     true,
+		},
+		{
+			name:     "path-template still hashes the query",
+			strategy: StrategyPathTemplate,
+			opts:     queryOpts,
+			pattern:  users,
+			a:        request{method: "GET", target: "/api/users/1?fields=name"},
+			b:        request{method: "GET", target: "/api/users/1?fields=email"},
+		},
+		{
+			name:     "json canonicalizes bodies",
+			strategy: StrategyJSON,
+			a:        request{method: "POST", target: "/api/search", body: `{"a":1,"b":2}`},
+			b:        request{method: "POST", target: "/api/search", body: `{"b": 2, "a": 1}`},
+			same:     true,
+		},
+		{
+			name:     "json drops ignored fields",
+			strategy: StrategyJSON,
+			opts:     Options{Body: BodyOptions{Ignore: []*jsonpath.Path{jsonpath.MustParse("$.requestId")}}},
+			a:        request{method: "POST", target: "/api/search", body: `{"q":"x","requestId":"1"}`},
+			b:        request{method: "POST", target: "/api/search", body: `{"q":"x","requestId":"2"}`},
+			same:     true,
+		},
+		{
+			name:     "json keeps values apart",
+			strategy: StrategyJSON,
+			a:        request{method: "POST", target: "/api/search", body: `{"q":"x"}`},
+			b:        request{method: "POST", target: "/api/search", body: `{"q":"y"}`},
+		},
+		{
+			name:     "json hashes invalid JSON raw",
+			strategy: StrategyJSON,
+			a:        request{method: "POST", target: "/api/search", body: `{"q":`},
+			b:        request{method: "POST", target: "/api/search", body: `{"q":`},
+			same:     true,
+		},
+		{
+			name:     "header separates header values",
+			strategy: StrategyHeader,
+			headers:  []string{"Accept-Language"},
+			a:        request{method: "GET", target: "/", headers: map[string]string{"Accept-Language": "en"}},
+			b:        request{method: "GET", target: "/", headers: map[string]string{"Accept-Language": "de"}},
+		},
+		{
+			name:     "header replaces the configured headers",
+			strategy: StrategyHeader,
+			opts:     Options{Headers: HeaderOptions{Include: []string{"Accept"}}},
+			headers:  []string{"Accept-Language"},
+			a:        request{method: "GET", target: "/", headers: map[string]string{"Accept": "text/html"}},
+			b:        request{method: "GET", target: "/", headers: map[string]string{"Accept": "application/json"}},
+			same:     true,
+		},
+		{
+			name:     "header falls back to the configured headers",
+			strategy: StrategyHeader,
+			opts:     Options{Headers: HeaderOptions{Include: []string{"Accept"}}},
+			a:        request{method: "GET", target: "/", headers: map[string]string{"Accept": "text/html"}},
+			b:        request{method: "GET", target: "/", headers: map[string]string{"Accept": "application/json"}},
+		},
+		{
+			name:     "header tells a missing header from an empty one",
+			strategy: StrategyHeader,
+			headers:  []string{"X-Tenant"},
+			a:        request{method: "GET", target: "/"},
+			b:        request{method: "GET", target: "/", headers: map[string]string{"X-Tenant": "acme"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.strategy, tt.opts, tt.pattern, tt.headers)
+			if err != nil {
+				t.Fatalf("NewMatcher: %v", err)
+			}
+			a, b := tt.a.key(t, m), tt.b.key(t, m)
+			if len(a) != 64 {
+				t.Errorf("key %q is not a hex SHA256", a)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("keys equal = %v, want %v", a == b, tt.same)
+			}
+			if again := tt.a.key(t, m); again != a {
+				t.Errorf("key changed between calls: %s, then %s", a, again)
+			}
+		})
+	}
+}
+
+func TestNewMatcherErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		pattern  *route.Pattern
+		error    string
+	}{
+		{"path-template without a route", StrategyPathTemplate, nil, "requires a route pattern"},
+		{"header without headers", StrategyHeader, nil, "requires at least one header"},
+		{"unknown strategy", Strategy("fuzzy"), nil, "unknown matching strategy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMatcher(tt.strategy, Options{}, tt.pattern, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("NewMatcher = %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, name := range []string{"default", "exact", "path-template", "json", "header"} {
+		if s, err := ParseStrategy(name); err != nil || string(s) != name {
+			t.Errorf("ParseStrategy(%q) = %q, %v", name, s, err)
+		}
+	}
+	if s, err := ParseStrategy(""); err != nil || s != StrategyDefault {
+		t.Errorf("ParseStrategy(\"\") = %q, %v, want the default strategy", s, err)
+	}
+	if _, err := ParseStrategy("Exact"); err == nil {
+		t.Errorf("ParseStrategy(\"Exact\") succeeded, want an error")
+	}
+}
+
+func TestDefaultStrategyMatchesGenerate(t *testing.T) {
+	// Recordings made before matchers existed must keep their keys
+	opts := Options{Query: QueryOptions{Enabled: true}}
+	r := httptest.NewRequest("POST", "/api/items?b=2&a=1", strings.NewReader("payload"))
+	want, err := Generate(r, []byte("payload"), opts)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	m, _ := NewMatcher(StrategyDefault, opts, nil, nil)
+	if got, _ := m.Key(r, []byte("payload")); got != want {
+		t.Errorf("default strategy key = %s, want %s", got, want)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	exact := MatcherFunc(func(r *http.Request, body []byte) (string, error) { return "exact", nil })
+	fallback := MatcherFunc(func(r *http.Request, body []byte) (string, error) { return "fallback", nil })
+	rt := &Router{
+		Routes: []Route{
+			{Pattern: route.MustParse("POST /api/search"), Matcher: exact},
+			{Pattern: route.MustParse("/api/*"), Matcher: MatcherFunc(func(r *http.Request, body []byte) (string, error) { return "api", nil })},
+		},
+		Fallback: fallback,
+	}
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{"POST", "/api/search", "exact"},
+		{"GET", "/api/search", "api"},
+		{"GET", "/api/users/1", "api"},
+		{"GET", "/health", "fallback"},
+	}
+	for _, tt := range tests {
+		key, err := rt.Key(httptest.NewRequest(tt.method, tt.path, nil), nil)
+		if err != nil || key != tt.want {
+			t.Errorf("%s %s routed to %q (%v), want %q", tt.method, tt.path, key, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package hash
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// RepeatedKeys controls how query parameters that appear more than once are normalized
+type RepeatedKeys string
+
+const (
+	// RepeatedPreserve keeps every value in the order it appeared in the request
+	RepeatedPreserve RepeatedKeys = "preserve"
+	// RepeatedSort keeps every value, sorted lexically
+	RepeatedSort RepeatedKeys = "sort"
+	// RepeatedFirst keeps only the first value
+	RepeatedFirst RepeatedKeys = "first"
+	// RepeatedLast keeps only the last value
+	RepeatedLast RepeatedKeys = "last"
+)
+
+// QueryOptions controls how the query string participates in the hash
+type QueryOptions struct {
+	// Enabled includes the query string in the hash
+	Enabled bool
+	// Sort orders parameters by key so ?a=1&b=2 and ?b=2&a=1 hash the same
+	Sort bool
+	// Ignore lists parameters that never affect the hash (cache busters, nonces, ...)
+	Ignore []string
+	// Repeated controls how repeated keys such as ?id=1&id=2 are treated
+	Repeated RepeatedKeys
+}
+
+// NormalizeQuery returns a canonical form of rawQuery according to opts
+// Parameters are grouped by key in order of first appearance (or sorted when
+// opts.Sort is set) and re-encoded, so equivalent query strings produce the
+// same output regardless of escaping
+func NormalizeQuery(rawQuery string, opts QueryOptions) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	ignored := make(map[string]bool, len(opts.Ignore))
+	for _, name := range opts.Ignore {
+		ignored[name] = true
+	}
+
+	var keys []string
+	values := make(map[string][]string)
+
+	// Parse manually instead of using url.ParseQuery to keep parameter order
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		key = unescape(key)
+		value = unescape(value)
+
+		if ignored[key] {
+			continue
+		}
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], value)
+	}
+
+	if opts.Sort {
+		sort.Strings(keys)
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range repeatedValues(values[key], opts.Repeated) {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+		}
+	}
+
+	return b.String()
+}
+
+// repeatedValues applies the repeated-key policy to the values of a single parameter
+func repeatedValues(vals []string, mode RepeatedKeys) []string {
+	switch mode {
+	case RepeatedFirst:
+		return vals[:1]
+	case RepeatedLast:
+		return vals[len(vals)-1:]
+	case RepeatedSort:
+		sorted := make([]string, len(vals))
+		copy(sorted, vals)
+		sort.Strings(sorted)
+		return sorted
+	default:
+		return vals
+	}
+}
+
+// unescape decodes a query component, falling back to the raw text if it is malformed
+func unescape(s string) string {
+	decoded, err := url.QueryUnescape(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
@@ -0,0 +1,293 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath expression
+// Supported syntax is the subset that is useful for addressing fields in
+// request and response bodies:
+//
+//	$.field          object member
+//	$['field']       object member with special characters
+//	$.list[0]        array element (negative indexes count from the end)
+//	$.list[*], $.*   every element or member
+//	$..field         field at any depth
+//
+// The leading "$." may be omitted, so "meta.requestId" is the same as "$.meta.requestId"
+type Path struct {
+	raw   string
+	steps []step
+}
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepWildcard
+)
+
+// step is a single segment of a path
+type step struct {
+	kind    stepKind
+	name    string
+	index   int
+	descend bool // Matches at the current level and at any depth below it
+}
+
+// Parse compiles a JSONPath expression
+func Parse(expr string) (*Path, error) {
+	raw := strings.TrimSpace(expr)
+	if raw == "" {
+		return nil, fmt.Errorf("invalid JSONPath: empty expression")
+	}
+
+	s := raw
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+	} else if !strings.HasPrefix(s, ".") && !strings.HasPrefix(s, "[") {
+		s = "." + s
+	}
+
+	p := &Path{raw: raw}
+	for len(s) > 0 {
+		descend := false
+		switch {
+		case strings.HasPrefix(s, ".."):
+			descend = true
+			s = s[2:]
+		case s[0] == '.':
+			s = s[1:]
+		case s[0] != '[':
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", raw, s[0])
+		}
+
+		var st step
+		var err error
+		if strings.HasPrefix(s, "[") {
+			st, s, err = parseBracket(s)
+		} else {
+			st, s, err = parseName(s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q: %w", raw, err)
+		}
+		st.descend = descend
+		p.steps = append(p.steps, st)
+	}
+
+	if len(p.steps) == 0 {
+		return nil, fmt.Errorf("invalid JSONPath %q: path selects the whole document", raw)
+	}
+
+	return p, nil
+}
+
+// MustParse is like Parse but panics on invalid expressions
+func MustParse(expr string) *Path {
+	p, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the expression the path was compiled from
+func (p *Path) String() string {
+	return p.raw
+}
+
+// parseName parses a dotted member name or "*"
+func parseName(s string) (step, string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	if name == "" {
+		return step{}, s, fmt.Errorf("missing member name")
+	}
+	if name == "*" {
+		return step{kind: stepWildcard}, s[end:], nil
+	}
+	return step{kind: stepField, name: name}, s[end:], nil
+}
+
+// parseBracket parses [n], [*], ['name'] or ["name"]
+func parseBracket(s string) (step, string, error) {
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return step{}, s, fmt.Errorf("unterminated bracket")
+	}
+	inner := strings.TrimSpace(s[1:end])
+	rest := s[end+1:]
+
+	switch {
+	case inner == "*":
+		return step{kind: stepWildcard}, rest, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return step{kind: stepField, name: inner[1 : len(inner)-1]}, rest, nil
+	}
+
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return step{}, s, fmt.Errorf("invalid index %q", inner)
+	}
+	return step{kind: stepIndex, index: index}, rest, nil
+}
+
+// Delete removes every value matched by the path from doc, which must be a
+// value decoded by encoding/json into interface{}. Matched array elements are
+// removed from their array. The (possibly replaced) document is returned
+func (p *Path) Delete(doc interface{}) interface{} {
+	return apply(doc, p.steps, deleteOp{})
+}
+
+// Set assigns value to every location matched by the path in doc, creating
+// the object member named by the last step if it doesn't exist yet (except
+// for "..field", which only updates existing members). The
+// (possibly replaced) document is returned
+func (p *Path) Set(doc interface{}, value interface{}) interface{} {
+	// "$..field" would otherwise add field to every object in the document
+	if p.steps[len(p.steps)-1].descend {
+		return p.Replace(doc, func(interface{}) interface{} { return value })
+	}
+	return apply(doc, p.steps, setOp{value: value})
+}
+
+// Replace calls fn with every value matched by the path in doc and stores
+// the result in its place. The (possibly replaced) document is returned
+func (p *Path) Replace(doc interface{}, fn func(interface{}) interface{}) interface{} {
+	return apply(doc, p.steps, replaceOp{fn: fn})
+}
+
+// operation is applied to the container holding each value a path matches
+type operation interface {
+	atKey(m map[string]interface{}, key string)
+	atIndex(a []interface{}, i int) []interface{}
+}
+
+// deleteOp removes matched values
+type deleteOp struct{}
+
+func (deleteOp) atKey(m map[string]interface{}, key string) {
+	delete(m, key)
+}
+
+func (deleteOp) atIndex(a []interface{}, i int) []interface{} {
+	return append(a[:i:i], a[i+1:]...)
+}
+
+// setOp assigns a value, creating missing object members
+type setOp struct {
+	value interface{}
+}
+
+func (op setOp) atKey(m map[string]interface{}, key string) {
+	m[key] = op.value
+}
+
+func (op setOp) atIndex(a []interface{}, i int) []interface{} {
+	a[i] = op.value
+	return a
+}
+
+// replaceOp maps existing values through a function
+type replaceOp struct {
+	fn func(interface{}) interface{}
+}
+
+func (op replaceOp) atKey(m map[string]interface{}, key string) {
+	if v, ok := m[key]; ok {
+		m[key] = op.fn(v)
+	}
+}
+
+func (op replaceOp) atIndex(a []interface{}, i int) []interface{} {
+	a[i] = op.fn(a[i])
+	return a
+}
+
+// apply walks node along steps and runs op on every match, returning the
+// updated node (arrays may be reallocated when elements are removed)
+func apply(node interface{}, steps []step, op operation) interface{} {
+	st := steps[0]
+	rest := steps[1:]
+	last := len(rest) == 0
+
+	if st.descend {
+		// Match at this level first, then at every level below it
+		here := st
+		here.descend = false
+		node = apply(node, append([]step{here}, rest...), op)
+
+		switch n := node.(type) {
+		case map[string]interface{}:
+			for k, v := range n {
+				n[k] = apply(v, steps, op)
+			}
+		case []interface{}:
+			for i, v := range n {
+				n[i] = apply(v, steps, op)
+			}
+		}
+		return node
+	}
+
+	switch st.kind {
+	case stepField:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return node
+		}
+		if last {
+			op.atKey(m, st.name)
+		} else if child, ok := m[st.name]; ok {
+			m[st.name] = apply(child, rest, op)
+		}
+
+	case stepIndex:
+		a, ok := node.([]interface{})
+		if !ok {
+			return node
+		}
+		i := st.index
+		if i < 0 {
+			i += len(a)
+		}
+		if i < 0 || i >= len(a) {
+			return node
+		}
+		if last {
+			return op.atIndex(a, i)
+		}
+		a[i] = apply(a[i], rest, op)
+
+	case stepWildcard:
+		switch n := node.(type) {
+		case map[string]interface{}:
+			for k, v := range n {
+				if last {
+					op.atKey(n, k)
+				} else {
+					n[k] = apply(v, rest, op)
+				}
+			}
+		case []interface{}:
+			// Walk backwards so removals don't shift elements still to be visited
+			for i := len(n) - 1; i >= 0; i-- {
+				if last {
+					n = op.atIndex(n, i)
+				} else {
+					n[i] = apply(n[i], rest, op)
+				}
+			}
+			return n
+		}
+	}
+
+	return node
+}
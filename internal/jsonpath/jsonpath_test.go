@@ -0,0 +1,89 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr  string
+		steps []step
+	}{
+		{"$.user", []step{{kind: stepField, name: "user"}}},
+		{"user.id", []step{{kind: stepField, name: "user"}, {kind: stepField, name: "id"}}},
+		{"  $.user  ", []step{{kind: stepField, name: "user"}}},
+		{"$['x-request-id']", []step{{kind: stepField, name: "x-request-id"}}},
+		{`$["a.b"].c`, []step{{kind: stepField, name: "a.b"}, {kind: stepField, name: "c"}}},
+		{"$.items[2]", []step{{kind: stepField, name: "items"}, {kind: stepIndex, index: 2}}},
+		{"$.items[-1]", []step{{kind: stepField, name: "items"}, {kind: stepIndex, index: -1}}},
+		{"$.items[*].id", []step{{kind: stepField, name: "items"}, {kind: stepWildcard}, {kind: stepField, name: "id"}}},
+		{"$.*", []step{{kind: stepWildcard}}},
+		{"[0]", []step{{kind: stepIndex, index: 0}}},
+		{"$..updatedAt", []step{{kind: stepField, name: "updatedAt", descend: true}}},
+		{"$.meta..id", []step{{kind: stepField, name: "meta"}, {kind: stepField, name: "id", descend: true}}},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(p.steps, tt.steps) {
+			t.Errorf("Parse(%q) steps = %+v, want %+v", tt.expr, p.steps, tt.steps)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"   ",
+		"$",
+		"$.",
+		"$.items[",
+		"$.items[x]",
+		"$.a..",
+		"$x",
+	} {
+		if p, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", expr, p.steps)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		path string
+		doc  string
+		want string
+	}{
+		{"$.token", `{"token":"abc","id":1}`, `{"id":1}`},
+		{"$.missing", `{"id":1}`, `{"id":1}`},
+		{"$.user.token", `{"user":{"token":"abc","id":1}}`, `{"user":{"id":1}}`},
+		{"$.user.token", `{"user":"alice"}`, `{"user":"alice"}`},
+		{"$['x-id']", `{"x-id":"1","id":2}`, `{"id":2}`},
+		{"$.items[1]", `{"items":[1,2,3]}`, `{"items":[1,3]}`},
+		{"$.items[-1]", `{"items":[1,2,3]}`, `{"items":[1,2]}`},
+		{"$.items[5]", `{"items":[1,2,3]}`, `{"items":[1,2,3]}`},
+		{"$.items[*]", `{"items":[1,2,3]}`, `{"items":[]}`},
+		{"$.items[*].id", `{"items":[{"id":1,"n":"a"},{"id":2,"n":"b"}]}`, `{"items":[{"n":"a"},{"n":"b"}]}`},
+		{"$.*", `{"a":1,"b":2}`, `{}`},
+		{"$..ts", `{"ts":1,"a":{"ts":2,"b":[{"ts":3,"c":4}]}}`, `{"a":{"b":[{"c":4}]}}`},
+		{"[0]", `[1,2]`, `[2]`},
+	}
+	for _, tt := range tests {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatalf("bad test document %s: %v", tt.doc, err)
+		}
+		got, err := json.Marshal(MustParse(tt.path).Delete(doc))
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Delete(%s) of %s = %s, want %s", tt.path, tt.doc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package pack reads and writes packs: gzip-compressed tar archives of
+// recordings with a manifest, for sharing fixture sets between machines
+// A pack holds manifest.json and one recordings/<key>.json file per
+// recording, in the format of the file storage, so it can also be unpacked
+// with tar straight into a STORAGE_PATH directory
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// Version is the version of the pack format written by Write
+const Version = 1
+
+// Names of the files in a pack
+const (
+	manifestName  = "manifest.json"
+	recordingsDir = "recordings/"
+	recordingExt  = ".json"
+)
+
+// maxFileSize bounds the files Read accepts, so a corrupt pack can't exhaust
+// memory
+const maxFileSize = 256 << 20
+
+// Manifest describes the contents of a pack
+type Manifest struct {
+	Version      int               `json:"version"`          // Pack format version
+	Chameleon    string            `json:"chameleon"`        // Version of Chameleon that wrote the pack
+	CreatedAt    time.Time         `json:"created_at"`       // When the pack was written
+	HashStrategy string            `json:"hash_strategy"`    // Matching strategy the keys were computed with
+	Filter       string            `json:"filter,omitempty"` // Filters the recordings were selected with, empty for all
+	Recordings   int               `json:"recordings"`
+	Responses    int               `json:"responses"`            // More than Recordings when there are sequences
+	Namespaces   map[string]int    `json:"namespaces,omitempty"` // Recordings per namespace, "" for none
+	Checksums    map[string]string `json:"checksums"`            // Key -> "sha256:<hex>" of its recording file
+}
+
+// Entry is a recording in a pack
+type Entry struct {
+	Key       string
+	Recording *storage.CachedResponse
+}
+
+// Write writes a pack of entries to w
+// The version, counts and checksums of manifest are filled in from entries
+func Write(w io.Writer, manifest Manifest, entries []Entry) error {
+	manifest.Version = Version
+	manifest.Recordings = len(entries)
+	manifest.Responses = 0
+	manifest.Namespaces = make(map[string]int)
+	manifest.Checksums = make(map[string]string, len(entries))
+
+	files := make([][]byte, len(entries))
+	for i, entry := range entries {
+		data, err := json.MarshalIndent(entry.Recording, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", entry.Key, err)
+		}
+		files[i] = data
+		namespace, _ := storage.SplitKey(entry.Key)
+		manifest.Namespaces[namespace]++
+		manifest.Responses += len(entry.Recording.Responses())
+		manifest.Checksums[entry.Key] = checksum(data)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	add := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	// The manifest goes first, so tools can read it without unpacking everything
+	if err := add(manifestName, manifestData); err != nil {
+		return fmt.Errorf("failed to write pack: %w", err)
+	}
+	for i, entry := range entries {
+		if err := add(recordingsDir+entry.Key+recordingExt, files[i]); err != nil {
+			return fmt.Errorf("failed to write pack: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write pack: %w", err)
+	}
+	return nil
+}
+
+// Read reads a pack, checking its recordings against the manifest
+// Entries are sorted by key
+func Read(r io.Reader) (*Manifest, []Entry, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a pack: %w", err)
+	}
+	defer zr.Close()
+
+	var manifest *Manifest
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read pack: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxFileSize {
+			return nil, nil, fmt.Errorf("invalid pack: %s is too large (%d bytes)", header.Name, header.Size)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read pack: %w", err)
+		}
+
+		switch {
+		case header.Name == manifestName:
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid pack manifest: %w", err)
+			}
+		case strings.HasPrefix(header.Name, recordingsDir) && strings.HasSuffix(header.Name, recordingExt):
+			key := strings.TrimSuffix(strings.TrimPrefix(header.Name, recordingsDir), recordingExt)
+			files[key] = data
+		}
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("invalid pack: no %s", manifestName)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, nil, fmt.Errorf("unsupported pack version %d (expected %d)", manifest.Version, Version)
+	}
+	if len(files) != manifest.Recordings || len(manifest.Checksums) != manifest.Recordings {
+		return nil, nil, fmt.Errorf("invalid pack: the manifest lists %d recordings with %d checksums, the pack holds %d",
+			manifest.Recordings, len(manifest.Checksums), len(files))
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for key, data := range files {
+		want, ok := manifest.Checksums[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid pack: %s is not in the manifest", key)
+		}
+		if got := checksum(data); got != want {
+			return nil, nil, fmt.Errorf("invalid pack: checksum mismatch for %s (manifest %s, file %s)", key, want, got)
+		}
+		var cached storage.CachedResponse
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return nil, nil, fmt.Errorf("invalid pack: %s: %w", key, err)
+		}
+		entries = append(entries, Entry{Key: key, Recording: &cached})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return manifest, entries, nil
+}
+
+// checksum returns the digest of a recording file, as stored in manifests
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
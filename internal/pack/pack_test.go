@@ -0,0 +1,225 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func testEntries() []Entry {
+	sequenced := &storage.CachedResponse{
+		Method:   "GET",
+		Path:     "/api/job",
+		Response: storage.Response{StatusCode: 202, Body: storage.ResponseBody(`{"state":"pending"}`)},
+	}
+	sequenced.Append(storage.Response{StatusCode: 200, Body: storage.ResponseBody(`{"state":"done"}`)})
+
+	return []Entry{
+		{Key: "0a1b2c3d4e5f6a7b", Recording: &storage.CachedResponse{
+			Method:   "GET",
+			Path:     "/api/users",
+			Response: storage.Response{StatusCode: 200, Body: storage.ResponseBody("[]")},
+		}},
+		{Key: "auth/9f8e7d6c5b4a3f2e", Recording: sequenced},
+	}
+}
+
+// writePack writes a pack of testEntries
+func writePack(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	manifest := Manifest{Chameleon: "test", CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), HashStrategy: "default"}
+	if err := Write(&buf, manifest, testEntries()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// rewritePack copies a pack file by file through edit, which returns the new
+// contents of a file, or nil to leave it out
+func rewritePack(t *testing.T, pack []byte, edit func(name string, data []byte) []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(pack))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(zr)
+
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(zw)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		if data = edit(header.Name, data); data == nil {
+			continue
+		}
+		header.Size = int64(len(data))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		tw.Write(data)
+	}
+	tw.Close()
+	zw.Close()
+	return out.Bytes()
+}
+
+// editManifest returns an edit that changes the manifest of a pack
+func editManifest(t *testing.T, fn func(m *Manifest)) func(string, []byte) []byte {
+	return func(name string, data []byte) []byte {
+		if name != manifestName {
+			return data
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("manifest: %v", err)
+		}
+		fn(&m)
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("manifest: %v", err)
+		}
+		return data
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	manifest, entries, err := Read(bytes.NewReader(writePack(t)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if manifest.Version != Version || manifest.Recordings != 2 || manifest.Responses != 3 {
+		t.Errorf("manifest version %d with %d recordings and %d responses, want %d with 2 and 3",
+			manifest.Version, manifest.Recordings, manifest.Responses, Version)
+	}
+	if manifest.Namespaces[""] != 1 || manifest.Namespaces["auth"] != 1 {
+		t.Errorf("manifest namespaces = %v, want one recording in each", manifest.Namespaces)
+	}
+
+	want := testEntries()
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.Key != want[i].Key {
+			t.Errorf("entry %d has key %s, want %s", i, entry.Key, want[i].Key)
+		}
+		got, wantResponses := entry.Recording.Responses(), want[i].Recording.Responses()
+		if len(got) != len(wantResponses) {
+			t.Errorf("%s has %d responses, want %d", entry.Key, len(got), len(wantResponses))
+			continue
+		}
+		for j := range got {
+			// JSON bodies are stored as JSON, indented with the recording
+			if got[j].StatusCode != wantResponses[j].StatusCode || compact(t, got[j].Body) != compact(t, wantResponses[j].Body) {
+				t.Errorf("%s response %d = %d %q, want %d %q", entry.Key, j, got[j].StatusCode, got[j].Body, wantResponses[j].StatusCode, wantResponses[j].Body)
+			}
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	pack := writePack(t)
+	recording := recordingsDir + "auth/9f8e7d6c5b4a3f2e" + recordingExt
+
+	tests := []struct {
+		name  string
+		pack  []byte
+		error string
+	}{
+		{
+			name:  "not gzip",
+			pack:  []byte("not a pack"),
+			error: "not a pack",
+		},
+		{
+			name: "tampered recording",
+			pack: rewritePack(t, pack, func(name string, data []byte) []byte {
+				if name == recording {
+					return bytes.Replace(data, []byte(`"done"`), []byte(`"failed"`), 1)
+				}
+				return data
+			}),
+			error: "checksum mismatch for auth/9f8e7d6c5b4a3f2e",
+		},
+		{
+			name: "missing recording",
+			pack: rewritePack(t, pack, func(name string, data []byte) []byte {
+				if name == recording {
+					return nil
+				}
+				return data
+			}),
+			error: "the manifest lists 2 recordings with 2 checksums, the pack holds 1",
+		},
+		{
+			name: "wrong recording count",
+			pack: rewritePack(t, pack, editManifest(t, func(m *Manifest) {
+				m.Recordings = 3
+			})),
+			error: "the manifest lists 3 recordings with 2 checksums, the pack holds 2",
+		},
+		{
+			name: "recording not in the manifest",
+			pack: rewritePack(t, pack, editManifest(t, func(m *Manifest) {
+				m.Checksums["auth/other"] = m.Checksums["auth/9f8e7d6c5b4a3f2e"]
+				delete(m.Checksums, "auth/9f8e7d6c5b4a3f2e")
+			})),
+			error: "auth/9f8e7d6c5b4a3f2e is not in the manifest",
+		},
+		{
+			name: "newer version",
+			pack: rewritePack(t, pack, editManifest(t, func(m *Manifest) {
+				m.Version = Version + 1
+			})),
+			error: "unsupported pack version",
+		},
+		{
+			name: "no manifest",
+			pack: rewritePack(t, pack, func(name string, data []byte) []byte {
+				if name == manifestName {
+					return nil
+				}
+				return data
+			}),
+			error: "no manifest.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Read(bytes.NewReader(tt.pack))
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Read = %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+}
+
+// compact returns a JSON body without insignificant whitespace
+func compact(t *testing.T, body []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		t.Fatalf("body %q is not JSON: %v", body, err)
+	}
+	return buf.String()
+}
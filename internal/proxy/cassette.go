@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// CassetteHeader selects the cassette of a single request, overriding the one
+// selected through the admin API or the configuration
+// It is removed before the request is hashed or forwarded
+const CassetteHeader = "X-Chameleon-Cassette"
+
+// Admin API paths; requests to them are answered by Chameleon itself and
+// never reach the backend
+const (
+	adminPrefix    = "/__chameleon/"
+	adminCassette  = adminPrefix + "cassette"
+	adminSequences = adminPrefix + "sequences"
+)
+
+// Sources of the active cassette, as reported by the admin API
+const (
+	cassetteFromConfig = "config"
+	cassetteFromAPI    = "api"
+)
+
+// cassetteFor returns the cassette a request is served from
+func (h *Handler) cassetteFor(r *http.Request) (string, error) {
+	if name := r.Header.Get(CassetteHeader); name != "" {
+		if !storage.ValidCassette(name) {
+			return "", fmt.Errorf("invalid %s %q: must be letters, digits, '.', '_' or '-'", CassetteHeader, name)
+		}
+		return name, nil
+	}
+	name, _ := h.activeCassette()
+	return name, nil
+}
+
+// activeCassette returns the cassette requests without a cassette header are
+// served from, and where it was selected
+func (h *Handler) activeCassette() (name, source string) {
+	if selected := h.cassette.Load(); selected != nil {
+		return *selected, cassetteFromAPI
+	}
+	return h.config.Cassette, cassetteFromConfig
+}
+
+// cassetteStatus is the body of admin API responses
+type cassetteStatus struct {
+	Cassette string `json:"cassette"` // Empty for the default set of recordings
+	Source   string `json:"source"`   // config or api
+}
+
+// serveAdmin answers requests to the admin API
+//
+//	GET    /__chameleon/cassette   returns the active cassette
+//	PUT    /__chameleon/cassette   selects the cassette named by {"cassette": "name"}
+//	DELETE /__chameleon/cassette   goes back to the cassette of the configuration
+//	DELETE /__chameleon/sequences  replays every sequence from its first response again
+func (h *Handler) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case adminCassette:
+		h.serveCassette(w, r)
+	case adminSequences:
+		h.serveSequences(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCassette answers requests to select the active cassette
+// Switching cassettes starts a new scenario, so sequences start over
+func (h *Handler) serveCassette(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req cassetteStatus
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		req.Cassette = strings.TrimSpace(req.Cassette)
+		if req.Cassette != "" && !storage.ValidCassette(req.Cassette) {
+			http.Error(w, fmt.Sprintf("invalid cassette %q: must be letters, digits, '.', '_' or '-'", req.Cassette), http.StatusBadRequest)
+			return
+		}
+		h.cassette.Store(&req.Cassette)
+		h.sequences.reset()
+		h.logger.Printf("[CASSETTE] Switched to %s", describeCassette(req.Cassette))
+	case http.MethodDelete:
+		h.cassette.Store(nil)
+		h.sequences.reset()
+		h.logger.Printf("[CASSETTE] Switched back to %s from the configuration", describeCassette(h.config.Cassette))
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, source := h.activeCassette()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cassetteStatus{Cassette: name, Source: source})
+}
+
+// describeCassette names a cassette for log messages
+func describeCassette(name string) string {
+	if name == "" {
+		return "the default recordings"
+	}
+	return "cassette " + name
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// doCassette sends a GET request for target through h, in cassette if it isn't empty
+func doCassette(t *testing.T, h http.Handler, cassette, target string) *http.Response {
+	t.Helper()
+	r := httptest.NewRequest("GET", target, nil)
+	if cassette != "" {
+		r.Header.Set(CassetteHeader, cassette)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Result()
+}
+
+// adminCassetteStatus sends a request to the cassette admin API and decodes its answer
+func adminCassetteStatus(t *testing.T, h http.Handler, method, body string) cassetteStatus {
+	t.Helper()
+	resp := do(h, method, adminCassette, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: status %d", method, adminCassette, resp.StatusCode)
+	}
+	var status cassetteStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decoding the status: %v", err)
+	}
+	return status
+}
+
+func TestCassettes(t *testing.T) {
+	backend := newCountingBackend(t)
+	st := storage.NewMemoryStore()
+	recorder := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+	for _, cassette := range []string{"", "checkout", "refund"} {
+		doCassette(t, recorder, cassette, "/api/order")
+		recorder.Wait()
+	}
+	keys, _ := st.List()
+	if len(keys) != 3 {
+		t.Fatalf("List = %v, want a recording per cassette", keys)
+	}
+
+	cfg := testConfig(config.ModeReplay, backend.URL)
+	cfg.Cassette = "checkout"
+	h := newTestHandler(t, cfg, st)
+	replay := func(cassette string) string {
+		resp := doCassette(t, h, cassette, "/api/order")
+		if resp.StatusCode == http.StatusNotFound {
+			return "404"
+		}
+		return readBody(t, resp)
+	}
+
+	// The configured cassette, unless the request names another
+	if got := replay(""); got != "2" {
+		t.Errorf("replay from the configured cassette = %s, want 2", got)
+	}
+	if got := replay("refund"); got != "3" {
+		t.Errorf("replay from the refund cassette header = %s, want 3", got)
+	}
+	if got := replay("unknown"); got != "404" {
+		t.Errorf("replay from an empty cassette = %s, want 404", got)
+	}
+	if resp := doCassette(t, h, "../refund", "/api/order"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("replay from an invalid cassette: status %d, want 400", resp.StatusCode)
+	}
+
+	// The admin API switches the cassette until it is deleted
+	if status := adminCassetteStatus(t, h, "GET", ""); status != (cassetteStatus{Cassette: "checkout", Source: cassetteFromConfig}) {
+		t.Errorf("GET %s = %+v, want the configured cassette", adminCassette, status)
+	}
+	if status := adminCassetteStatus(t, h, "PUT", `{"cassette": "refund"}`); status != (cassetteStatus{Cassette: "refund", Source: cassetteFromAPI}) {
+		t.Errorf("PUT %s = %+v, want the refund cassette", adminCassette, status)
+	}
+	if got := replay(""); got != "3" {
+		t.Errorf("replay after selecting refund = %s, want 3", got)
+	}
+	if status := adminCassetteStatus(t, h, "PUT", `{"cassette": ""}`); status.Cassette != "" {
+		t.Errorf("PUT %s = %+v, want the default recordings", adminCassette, status)
+	}
+	if got := replay(""); got != "1" {
+		t.Errorf("replay after selecting the default recordings = %s, want 1", got)
+	}
+	if status := adminCassetteStatus(t, h, "DELETE", ""); status.Cassette != "checkout" {
+		t.Errorf("DELETE %s = %+v, want the configured cassette", adminCassette, status)
+	}
+	if got := replay(""); got != "2" {
+		t.Errorf("replay after DELETE = %s, want 2", got)
+	}
+
+	for _, tt := range []struct{ method, body string }{{"PUT", `{"cassette": "a/b"}`}, {"PUT", "{"}, {"PATCH", ""}} {
+		if resp := do(h, tt.method, adminCassette, tt.body); resp.StatusCode < 400 {
+			t.Errorf("%s %s %s: status %d, want an error", tt.method, adminCassette, tt.body, resp.StatusCode)
+		}
+	}
+
+	// Admin requests never reach the backend, even when recording
+	if resp := do(recorder, "GET", "/__chameleon/unknown", ""); resp.StatusCode != http.StatusNotFound || !strings.Contains(readBody(t, resp), "not found") {
+		t.Errorf("unknown admin path: status %d, want 404", resp.StatusCode)
+	}
+}
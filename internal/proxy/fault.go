@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/route"
+)
+
+// faultRule is a compiled config.FaultRule
+type faultRule struct {
+	config.FaultRule
+	pattern *route.Pattern
+}
+
+// String describes the fault for log lines and the X-Chameleon-Fault header
+func (f *faultRule) String() string {
+	switch f.Action {
+	case config.FaultStatus:
+		return fmt.Sprintf("%s:%d", f.Action, f.Status)
+	case config.FaultTruncate:
+		return fmt.Sprintf("%s:%d", f.Action, f.Bytes)
+	default:
+		return f.Action
+	}
+}
+
+// newFaultRules compiles the fault injection rules
+func newFaultRules(rules []config.FaultRule) ([]*faultRule, error) {
+	compiled := make([]*faultRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := route.Parse(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault rule: %w", err)
+		}
+		compiled = append(compiled, &faultRule{FaultRule: rule, pattern: pattern})
+	}
+	return compiled, nil
+}
+
+// pickFault returns the fault to inject into r, if any
+// Every matching rule gets a chance to fire in order; the first one that does wins
+func (h *Handler) pickFault(r *http.Request) *faultRule {
+	for _, rule := range h.faults {
+		if rule.pattern.MatchRequest(r) && rand.Float64() < rule.Probability {
+			return rule
+		}
+	}
+	return nil
+}
+
+// injectFault applies fault to the request
+// Returns the writer the request should be served with, or nil if the fault
+// has already answered it
+func (h *Handler) injectFault(w http.ResponseWriter, r *http.Request, fault *faultRule) http.ResponseWriter {
+	h.logger.Printf("[FAULT] Injecting %s into %s %s", fault, r.Method, r.URL.RequestURI())
+
+	switch fault.Action {
+	case config.FaultStatus:
+		w.Header().Set("X-Chameleon-Fault", fault.String())
+		http.Error(w, fmt.Sprintf("%d %s (injected fault)", fault.Status, http.StatusText(fault.Status)), fault.Status)
+		return nil
+
+	case config.FaultDrop:
+		panic(http.ErrAbortHandler)
+
+	case config.FaultHang:
+		select {
+		case <-r.Context().Done():
+			h.logger.Printf("[FAULT] Client gave up on hanging request: %s %s", r.Method, r.URL.RequestURI())
+		case <-h.stopping:
+		}
+		panic(http.ErrAbortHandler)
+
+	case config.FaultTruncate:
+		return &truncatingWriter{ResponseWriter: w, handler: h, limit: fault.Bytes}
+	}
+
+	return w
+}
+
+// Stop releases requests held open by hang faults and closes active WebSocket
+// sessions, so that a graceful shutdown doesn't wait for them. Sessions being
+// recorded are saved with the messages received so far. It is safe to call
+// more than once
+func (h *Handler) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stopping)
+	})
+	h.closeSessions()
+}
+
+// truncatingWriter lets the first limit bytes of a response body through and
+// then aborts the connection, so the client sees a response cut short
+type truncatingWriter struct {
+	http.ResponseWriter
+	handler *Handler
+	limit   int
+	written int
+	cut     bool // Set once the body has been cut
+}
+
+func (tw *truncatingWriter) Write(b []byte) (int, error) {
+	if tw.written+len(b) <= tw.limit {
+		n, err := tw.ResponseWriter.Write(b)
+		tw.written += n
+		return n, err
+	}
+
+	n, _ := tw.ResponseWriter.Write(b[:tw.limit-tw.written])
+	tw.written += n
+	tw.cut = true
+	// Make sure the headers and the partial body reach the client before the connection goes
+	_ = http.NewResponseController(tw.ResponseWriter).Flush()
+	tw.handler.logger.Printf("[FAULT] Truncated response body after %d bytes", tw.written)
+	panic(http.ErrAbortHandler)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (tw *truncatingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
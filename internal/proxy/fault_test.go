@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault config.FaultRule
+		// Expected outcome: status and body of the response, or that the request failed
+		status int
+		body   string
+		failed bool
+	}{
+		{"status", config.FaultRule{Match: "*", Action: config.FaultStatus, Status: 503, Probability: 1}, 503, "503 Service Unavailable (injected fault)\n", false},
+		{"drop", config.FaultRule{Match: "*", Action: config.FaultDrop, Probability: 1}, 0, "", true},
+		{"truncate", config.FaultRule{Match: "*", Action: config.FaultTruncate, Bytes: 4, Probability: 1}, 201, "GET ", true},
+		{"never fires", config.FaultRule{Match: "*", Action: config.FaultStatus, Status: 503, Probability: 0}, 201, "GET /api/users ", false},
+		{"other route", config.FaultRule{Match: "POST /api/*", Action: config.FaultDrop, Probability: 1}, 201, "GET /api/users ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t)
+			st := storage.NewMemoryStore()
+			cfg := testConfig(config.ModeRecord, backend.URL)
+			cfg.Faults = []config.FaultRule{tt.fault}
+			h := newTestHandler(t, cfg, st)
+			proxy := httptest.NewServer(h)
+			defer proxy.Close()
+
+			resp, err := http.Get(proxy.URL + "/api/users")
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != tt.status {
+					t.Errorf("status %d, want %d", resp.StatusCode, tt.status)
+				}
+			}
+			if failed := err != nil; failed != tt.failed {
+				t.Errorf("request failed = %v (%v), want %v", failed, err, tt.failed)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body %q, want %q", body, tt.body)
+			}
+
+			// Only responses the client received in full are recorded
+			h.Wait()
+			keys, _ := st.List()
+			if recorded, want := len(keys) > 0, tt.status == 201 && !tt.failed; recorded != want {
+				t.Errorf("recorded = %v, want %v", recorded, want)
+			}
+		})
+	}
+}
+
+func TestHangReleasedByStop(t *testing.T) {
+	backend := newTestBackend(t)
+	cfg := testConfig(config.ModeRecord, backend.URL)
+	cfg.Faults = []config.FaultRule{{Match: "*", Action: config.FaultHang, Probability: 1}}
+	h := newTestHandler(t, cfg, storage.NewMemoryStore())
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+
+	failed := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(proxy.URL + "/api/users")
+		if err == nil {
+			resp.Body.Close()
+		}
+		failed <- err
+	}()
+
+	select {
+	case <-failed:
+		t.Fatalf("hanging request answered before Stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+	h.Stop()
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Errorf("hanging request answered after Stop, want the connection dropped")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("hanging request still open after Stop")
+	}
+	if n := backend.requests.Load(); n != 0 {
+		t.Errorf("backend received %d requests, want none", n)
+	}
+}
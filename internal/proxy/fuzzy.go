@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/yourusername/chameleon/internal/hash"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// Response headers added when a nearest-match recording is served
+const (
+	headerSubstitute = "X-Chameleon-Substitute"
+	headerMatchScore = "X-Chameleon-Match-Score"
+)
+
+// nearestMatch is a recording chosen as a stand-in for a request with no exact match
+type nearestMatch struct {
+	hash   string
+	cached *storage.CachedResponse
+	score  float64
+}
+
+// findNearest searches recordings in the namespace of key with the same method
+// and path as r and returns the one whose query and body are most similar, or
+// nil if none reaches the configured threshold
+func (h *Handler) findNearest(r *http.Request, key string, body []byte) (*nearestMatch, error) {
+	hashes, err := h.nearestCandidates(r)
+	if err != nil {
+		return nil, err
+	}
+	namespace, _ := storage.SplitKey(key)
+
+	query := queryFields(r.URL.RawQuery, h.hashOpts.Query)
+	fields := bodyFields(body)
+
+	var best *nearestMatch
+	for _, candidate := range hashes {
+		// Recordings of another backend are never a stand-in
+		if ns, _ := storage.SplitKey(candidate); ns != namespace {
+			continue
+		}
+		cached, err := h.storage.Load(candidate)
+		if err != nil {
+			h.logger.Printf("[REPLAY] Skipping unreadable recording %s: %v", candidate, err)
+			continue
+		}
+		if cached.Method != r.Method || cached.Path != r.URL.Path {
+			continue
+		}
+
+		// Recordings made before requests were stored only match on method and path
+		var recordedQuery, recordedBody map[string]string
+		if cached.Request != nil {
+			recordedQuery = queryFields(cached.Request.RawQuery(), h.hashOpts.Query)
+			recordedBody = bodyFields(cached.Request.Body)
+		}
+
+		score := similarity([2][2]map[string]string{
+			{query, recordedQuery},
+			{fields, recordedBody},
+		})
+		if best == nil || score > best.score {
+			best = &nearestMatch{hash: candidate, cached: cached, score: score}
+		}
+	}
+
+	if best == nil || best.score < h.config.Fuzzy.Threshold {
+		return nil, nil
+	}
+	return best, nil
+}
+
+// nearestCandidates returns the keys of the recordings with the method and
+// path of r, from the indexes of the store if it has them
+func (h *Handler) nearestCandidates(r *http.Request) ([]string, error) {
+	searcher, ok := h.storage.(storage.Searcher)
+	if !ok {
+		return h.paths.lookup(h.storage, r.Method, r.URL.Path)
+	}
+
+	found, err := searcher.Search(storage.Query{Method: r.Method, PathPrefix: r.URL.Path})
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, s := range found {
+		if s.Path == r.URL.Path {
+			keys = append(keys, s.Key)
+		}
+	}
+	return keys, nil
+}
+
+// pathIndex maps method and path to the keys of their recordings, so that
+// nearest matches don't read every recording of stores without indexes
+// It is built on the first lookup and kept up to date with the recordings the
+// proxy saves; recordings other processes add are seen after a reload
+type pathIndex struct {
+	mu   sync.Mutex
+	keys map[string]map[string]bool // "METHOD path" -> keys, nil until built
+}
+
+// lookup returns the keys of the recordings of method and path
+func (x *pathIndex) lookup(st storage.Store, method, path string) ([]string, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.keys == nil {
+		keys := make(map[string]map[string]bool)
+		err := st.Iterate(func(key string, cached *storage.CachedResponse, err error) error {
+			if err == nil {
+				addKey(keys, key, cached)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		x.keys = keys
+	}
+
+	var found []string
+	for key := range x.keys[method+" "+path] {
+		found = append(found, key)
+	}
+	return found, nil
+}
+
+// add records that cached was saved under key, if the index is built
+func (x *pathIndex) add(key string, cached *storage.CachedResponse) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.keys != nil {
+		addKey(x.keys, key, cached)
+	}
+}
+
+// reset drops the index, to be built again on the next lookup
+func (x *pathIndex) reset() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.keys = nil
+}
+
+// addKey adds key to the index entry of its recording
+func addKey(index map[string]map[string]bool, key string, cached *storage.CachedResponse) {
+	entry := cached.Method + " " + cached.Path
+	if index[entry] == nil {
+		index[entry] = make(map[string]bool)
+	}
+	index[entry][key] = true
+}
+
+// setSubstituteHeaders tells the client which recording stood in for its request
+func setSubstituteHeaders(w http.ResponseWriter, match *nearestMatch) {
+	w.Header().Set(headerSubstitute, match.hash)
+	w.Header().Set(headerMatchScore, fmt.Sprintf("%.2f", match.score))
+}
+
+// queryFields returns the normalized parameters of a query string, with
+// repeated values joined
+func queryFields(rawQuery string, opts hash.QueryOptions) map[string]string {
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(hash.NormalizeQuery(rawQuery, opts), "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if prev, ok := fields[key]; ok {
+			value = prev + "," + value
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// bodyFields breaks a body into comparable fields: path/value pairs for the
+// scalars of a JSON document, or its words (with empty values) for anything else
+func bodyFields(body []byte) map[string]string {
+	fields := make(map[string]string)
+	if len(bytes.TrimSpace(body)) == 0 {
+		return fields
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err == nil {
+		flattenJSON("$", doc, fields)
+		return fields
+	}
+
+	for _, word := range strings.FieldsFunc(string(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		fields[word] = ""
+	}
+	return fields
+}
+
+// flattenJSON records the value of every scalar in a JSON document by path
+func flattenJSON(path string, node interface{}, fields map[string]string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			flattenJSON(path+"."+k, v, fields)
+		}
+	case []interface{}:
+		for i, v := range n {
+			flattenJSON(fmt.Sprintf("%s[%d]", path, i), v, fields)
+		}
+	default:
+		value, _ := json.Marshal(n)
+		fields[path] = string(value)
+	}
+}
+
+// similarity averages fieldSimilarity over each pair of field sets
+// Pairs where both sides are empty (e.g. no body on a GET) carry no signal and
+// are left out, so they don't inflate the score
+func similarity(pairs [2][2]map[string]string) float64 {
+	total, counted := 0.0, 0
+	for _, pair := range pairs {
+		if len(pair[0]) == 0 && len(pair[1]) == 0 {
+			continue
+		}
+		total += fieldSimilarity(pair[0], pair[1])
+		counted++
+	}
+	if counted == 0 {
+		return 1
+	}
+	return total / float64(counted)
+}
+
+// fieldSimilarity scores two field sets between 0 and 1: every field present
+// on both sides counts fully if the values are equal and half if they differ,
+// averaged over all fields seen on either side
+func fieldSimilarity(a, b map[string]string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	score, union := 0.0, len(b)
+	for key, av := range a {
+		bv, ok := b[key]
+		switch {
+		case !ok:
+			union++
+		case av == bv:
+			score++
+		default:
+			score += 0.5
+		}
+	}
+	return score / float64(union)
+}
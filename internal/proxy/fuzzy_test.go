@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func TestReplayNearest(t *testing.T) {
+	// The memory store is searched through the path index, bolt through its own
+	backends := map[string]func(t *testing.T) storage.Store{
+		storage.BackendMemory: func(t *testing.T) storage.Store { return storage.NewMemoryStore() },
+		storage.BackendBolt: func(t *testing.T) storage.Store {
+			st, err := storage.Open(storage.BackendBolt, filepath.Join(t.TempDir(), "recordings.db"))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			t.Cleanup(func() { storage.Close(st) })
+			return st
+		},
+	}
+	tests := []struct {
+		method, target, body string
+		substitute           bool
+	}{
+		{"GET", "/search?q=a&page=2", "", true},
+		{"GET", "/search?q=b&page=2", "", true},
+		{"GET", "/search?other=1", "", false},
+		{"GET", "/search/more?q=a&page=1", "", false},
+		{"POST", "/search?q=a&page=1", "", false},
+		{"POST", "/items", `{"name":"a","size":2}`, true},
+		{"POST", "/items", `{"id":9}`, false},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			backend := newTestBackend(t)
+			st := open(t)
+			recorder := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+			do(recorder, "GET", "/search?q=a&page=1", "")
+			do(recorder, "POST", "/items", `{"name":"a","size":1}`)
+			recorder.Wait()
+
+			cfg := testConfig(config.ModeReplay, backend.URL)
+			cfg.Fuzzy.Enabled = true
+			replayer := newTestHandler(t, cfg, st)
+			for _, tt := range tests {
+				resp := do(replayer, tt.method, tt.target, tt.body)
+				substituted := resp.Header.Get(headerSubstitute) != ""
+				if substituted != tt.substitute {
+					t.Errorf("%s %s %s: substituted = %v, want %v", tt.method, tt.target, tt.body, substituted, tt.substitute)
+				}
+				want := http.StatusNotFound
+				if tt.substitute {
+					want = http.StatusCreated
+				}
+				if resp.StatusCode != want {
+					t.Errorf("%s %s %s: status %d, want %d", tt.method, tt.target, tt.body, resp.StatusCode, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name  string
+		query [2]map[string]string
+		body  [2]map[string]string
+		want  float64
+	}{
+		{"nothing to compare", [2]map[string]string{}, [2]map[string]string{}, 1},
+		{"equal", [2]map[string]string{{"q": "a"}, {"q": "a"}}, [2]map[string]string{}, 1},
+		{"changed value", [2]map[string]string{{"q": "a", "page": "1"}, {"q": "a", "page": "2"}}, [2]map[string]string{}, 0.75},
+		{"missing field", [2]map[string]string{{"q": "a"}, {"q": "a", "page": "1"}}, [2]map[string]string{}, 0.5},
+		{"disjoint", [2]map[string]string{{"q": "a"}, {"page": "1"}}, [2]map[string]string{}, 0},
+		{"query and body", [2]map[string]string{{"q": "a"}, {"q": "a"}}, [2]map[string]string{{"$.id": "1"}, {"$.id": "2"}}, 0.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := similarity([2][2]map[string]string{tt.query, tt.body}); got != tt.want {
+				t.Errorf("similarity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
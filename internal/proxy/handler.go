@@ -5,57 +5,159 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/yourusername/chameleon/internal/config"
 	"github.com/yourusername/chameleon/internal/hash"
+	"github.com/yourusername/chameleon/internal/jsonpath"
+	"github.com/yourusername/chameleon/internal/route"
 	"github.com/yourusername/chameleon/internal/storage"
+	"github.com/yourusername/chameleon/internal/transform"
 )
 
 // Handler implements the HTTP proxy handler
+// Each configuration loaded by New or Reload is served by a Handler of its
+// own; they share the state that outlives a reload
 type Handler struct {
-	config  *config.Config
-	storage *storage.Storage
-	proxy   *httputil.ReverseProxy
+	*shared
+	config *config.Config
+
+	// routes pick the backend and storage namespace of a request; fallback
+	// serves requests matched by no route
+	routes   []*backendRoute
+	fallback *backendRoute
+
+	// matcher maps requests to storage keys
+	matcher hash.Matcher
+	// hashOpts are the configured hashing options, used to normalize queries
+	// for nearest matches and to redact secret headers in recordings
+	hashOpts hash.Options
+
+	// latencyRules select the simulated latency per route
+	latencyRules []latencyRule
+
+	// transforms rewrite recorded responses before they are replayed
+	transforms *transform.Pipeline
+
+	// rewrites modify requests before they are forwarded to the backend
+	rewrites []*rewriteRule
+
+	// faults are the fault injection rules
+	faults []*faultRule
+}
+
+// shared is the state of a handler that is kept across configuration reloads
+type shared struct {
+	storage storage.Store
 	logger  *log.Logger
+
+	// current is the handler serving new requests
+	current atomic.Pointer[Handler]
+
+	// sequences tracks replay positions within recorded response sequences
+	sequences *sequencer
+
+	// paths indexes recordings by method and path for nearest matches
+	paths *pathIndex
+
+	// cassette is the cassette selected through the admin API, nil to use the
+	// one of the configuration
+	cassette atomic.Pointer[string]
+
+	// stopping is closed by Stop to release requests held open by hang faults
+	stopping chan struct{}
+	stopOnce sync.Once
+
+	// saves tracks recordings that are being written to storage, and
+	// WebSocket sessions that are saved once they end
+	saves sync.WaitGroup
+
+	// sessions holds the hijacked connections of active WebSocket sessions,
+	// which the HTTP server no longer tracks, so Stop can close them
+	sessionsMu sync.Mutex
+	sessions   map[net.Conn]struct{}
 }
 
 // New creates a new proxy handler
-func New(cfg *config.Config, st *storage.Storage, logger *log.Logger) (*Handler, error) {
-	backendURL, err := url.Parse(cfg.BackendURL)
+func New(cfg *config.Config, st storage.Store, logger *log.Logger) (*Handler, error) {
+	s := &shared{
+		storage:   st,
+		logger:    logger,
+		sequences: newSequencer(),
+		paths:     &pathIndex{},
+		stopping:  make(chan struct{}),
+		sessions:  make(map[net.Conn]struct{}),
+	}
+	h, err := s.newHandler(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("invalid backend URL: %w", err)
+		return nil, err
 	}
+	s.current.Store(h)
+	return h, nil
+}
 
-	proxy := httputil.NewSingleHostReverseProxy(backendURL)
+// Reload switches the handler to cfg. Requests already in flight finish under
+// the previous configuration. If cfg is invalid the error is returned and the
+// previous configuration stays in effect
+// Storage is kept; changes to the storage path or port are ignored
+func (h *Handler) Reload(cfg *config.Config) error {
+	next, err := h.shared.newHandler(cfg)
+	if err != nil {
+		return err
+	}
+	h.current.Store(next)
+	// Pick up recordings added by other processes, such as an import, and
+	// replay sequences from the start under the new configuration
+	h.paths.reset()
+	h.sequences.reset()
+	return nil
+}
+
+// newHandler builds the handler serving cfg
+func (s *shared) newHandler(cfg *config.Config) (*Handler, error) {
+	matcher, err := newMatcher(cfg.Hash)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := hashOptions(cfg.Hash)
+	if err != nil {
+		return nil, err
+	}
+	latencyRules, err := newLatencyRules(cfg.Latency.Rules)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := newFaultRules(cfg.Faults)
+	if err != nil {
+		return nil, err
+	}
+	transforms, err := newPipeline(cfg.Transforms)
+	if err != nil {
+		return nil, err
+	}
+	rewrites, err := newRewriteRules(cfg.Rewrites)
+	if err != nil {
+		return nil, err
+	}
 
 	h := &Handler{
-		config:  cfg,
-		storage: st,
-		proxy:   proxy,
-		logger:  logger,
-	}
-
-	// Customize the proxy director
-	originalDirector := proxy.Director
-	// Capture logger and cfg for the closure
-	loggerRef := logger
-	mode := cfg.Mode
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		req.Host = backendURL.Host
-
-		// In record mode, strip conditional headers to force full responses
-		// This prevents 304 (Not Modified) responses and ensures we get the actual resource
-		if mode == config.ModeRecord {
-			stripped := stripConditionalHeaders(req)
-			if stripped {
-				loggerRef.Printf("[RECORD] Stripped conditional headers to force full response")
-			}
-		}
+		shared: s,
+		config: cfg,
+
+		matcher:      matcher,
+		hashOpts:     opts,
+		latencyRules: latencyRules,
+		transforms:   transforms,
+		rewrites:     rewrites,
+		faults:       faults,
+	}
+	if h.routes, h.fallback, err = h.newBackendRoutes(cfg); err != nil {
+		return nil, err
 	}
 
 	return h, nil
@@ -63,6 +165,18 @@ func New(cfg *config.Config, st *storage.Storage, logger *log.Logger) (*Handler,
 
 // ServeHTTP implements http.Handler
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The request is served by the configuration current when it arrives,
+	// even if a reload happens while it is in flight
+	current := h.current.Load()
+	if strings.HasPrefix(r.URL.Path, adminPrefix) {
+		current.serveAdmin(w, r)
+		return
+	}
+	current.serve(w, r)
+}
+
+// serve handles a request under the configuration of h
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
 	// Read request body once (it will be consumed)
@@ -75,23 +189,42 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Restore body for downstream use
 	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
+	// The cassette header is meant for Chameleon, not for the key or the backend
+	cassette, err := h.cassetteFor(r)
+	if err != nil {
+		h.logger.Printf("[ERROR] %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Header.Del(CassetteHeader)
+
 	// Generate hash from request
-	requestHash, err := hash.Generate(r.Method, r.URL.Path, bytes.NewReader(bodyBytes))
+	requestHash, err := h.key(r, bodyBytes)
 	if err != nil {
 		h.logger.Printf("[ERROR] Failed to generate hash: %v", err)
 		http.Error(w, fmt.Sprintf("failed to generate hash: %v", err), http.StatusInternalServerError)
 		return
 	}
+	requestHash = storage.CassetteKey(cassette, requestHash)
 
 	// Log incoming request
 	h.logger.Printf("[%s] %s %s | Hash: %s | Mode: %s",
-		r.Method, r.URL.Path, r.RemoteAddr, requestHash[:16], h.config.Mode)
+		r.Method, r.URL.RequestURI(), r.RemoteAddr, shortKey(requestHash), h.config.Mode)
+
+	// Injected faults apply in every mode, in front of recordings and backend alike
+	if fault := h.pickFault(r); fault != nil {
+		if w = h.injectFault(w, r, fault); w == nil {
+			return
+		}
+	}
 
 	switch h.config.Mode {
-	case config.ModeReplay:
-		h.handleReplay(w, r, requestHash, start)
+	case config.ModeReplay, config.ModeReplayPassthrough:
+		h.handleReplay(w, r, requestHash, bodyBytes, start)
 	case config.ModeRecord:
 		h.handleRecord(w, r, requestHash, bodyBytes, start)
+	case config.ModeRecordMissing:
+		h.handleRecordMissing(w, r, requestHash, bodyBytes, start)
 	case config.ModePassthrough:
 		h.handlePassthrough(w, r, start)
 	default:
@@ -100,9 +233,106 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Key returns the storage key of a request: its hash under the configured
+// matching rules, in the namespace of the backend route it is sent to
+// The key is relative to the cassette of the request; see storage.CassetteKey
+func (h *Handler) Key(r *http.Request, body []byte) (string, error) {
+	return h.current.Load().key(r, body)
+}
+
+// key returns the storage key of a request under the configuration of h
+func (h *Handler) key(r *http.Request, body []byte) (string, error) {
+	hash, err := h.matcher.Key(r, body)
+	if err != nil {
+		return "", err
+	}
+	return storage.Key(h.routeFor(r).Name, hash), nil
+}
+
+// RedactHeaders returns header as recordings store it, with the values of
+// secret headers replaced by their digest
+func (h *Handler) RedactHeaders(header http.Header) map[string][]string {
+	return hash.RedactHeaders(header, h.current.Load().hashOpts.Headers)
+}
+
+// newMatcher builds the request matcher described by the hashing configuration
+func newMatcher(hc config.HashConfig) (hash.Matcher, error) {
+	opts, err := hashOptions(hc)
+	if err != nil {
+		return nil, err
+	}
+
+	fallback, err := hash.NewMatcher(hash.Strategy(hc.Strategy), opts, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(hc.Rules) == 0 {
+		return fallback, nil
+	}
+
+	router := &hash.Router{Fallback: fallback}
+	for _, rule := range hc.Rules {
+		pattern, err := route.Parse(rule.Match)
+		if err != nil {
+			return nil, err
+		}
+		m, err := hash.NewMatcher(hash.Strategy(rule.Strategy), opts, pattern, rule.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matching rule %q: %w", rule.Match, err)
+		}
+		router.Routes = append(router.Routes, hash.Route{Pattern: pattern, Matcher: m})
+	}
+
+	return router, nil
+}
+
+// hashOptions converts the hashing configuration into hash.Options
+func hashOptions(hc config.HashConfig) (hash.Options, error) {
+	ignore := make([]*jsonpath.Path, 0, len(hc.Body.Ignore))
+	for _, expr := range hc.Body.Ignore {
+		path, err := jsonpath.Parse(expr)
+		if err != nil {
+			return hash.Options{}, fmt.Errorf("invalid body ignore rule: %w", err)
+		}
+		ignore = append(ignore, path)
+	}
+
+	return hash.Options{
+		Query: hash.QueryOptions{
+			Enabled:  hc.Query.Enabled,
+			Sort:     hc.Query.Sort,
+			Ignore:   hc.Query.Ignore,
+			Repeated: hash.RepeatedKeys(hc.Query.Repeated),
+		},
+		Headers: hash.HeaderOptions{
+			Include: hc.Headers.Include,
+			Secret:  hc.Headers.Secret,
+		},
+		Body: hash.BodyOptions{
+			Mode:   hash.BodyMode(hc.Body.Mode),
+			Ignore: ignore,
+		},
+	}, nil
+}
+
+// Wait blocks until all in-flight recordings have been written to storage
+// It should be called after the HTTP server has stopped accepting requests
+func (h *Handler) Wait() {
+	h.saves.Wait()
+}
+
 // handleReplay serves cached responses if available
-func (h *Handler) handleReplay(w http.ResponseWriter, r *http.Request, requestHash string, start time.Time) {
+func (h *Handler) handleReplay(w http.ResponseWriter, r *http.Request, requestHash string, bodyBytes []byte, start time.Time) {
 	if !h.storage.Exists(requestHash) {
+		// The live backend is a better answer than a nearest match, and is never recorded
+		if h.config.Mode == config.ModeReplayPassthrough {
+			h.logger.Printf("[REPLAY] No cached response found for hash %s, falling back to backend", shortKey(requestHash))
+			h.handlePassthrough(w, r, start)
+			return
+		}
+		if h.config.Fuzzy.Enabled && h.replayNearest(w, r, requestHash, bodyBytes, start) {
+			return
+		}
 		h.logger.Printf("[REPLAY] No cached response found for hash: %s", requestHash)
 		http.Error(w, fmt.Sprintf("no cached response found for request (hash: %s)", requestHash), http.StatusNotFound)
 		return
@@ -115,9 +345,65 @@ func (h *Handler) handleReplay(w http.ResponseWriter, r *http.Request, requestHa
 		return
 	}
 
-	h.logger.Printf("[REPLAY] Serving cached response: %s %s | Status: %d | Hash: %s",
-		cached.Method, cached.Path, cached.StatusCode, requestHash[:16])
+	h.serveRecording(w, r, "REPLAY", requestHash, cached)
+
+	duration := time.Since(start)
+	h.logger.Printf("[REPLAY] Completed in %v", duration)
+}
+
+// replayNearest serves the most similar recording in place of a missing one
+// Returns false if no recording is similar enough
+func (h *Handler) replayNearest(w http.ResponseWriter, r *http.Request, requestHash string, bodyBytes []byte, start time.Time) bool {
+	match, err := h.findNearest(r, requestHash, bodyBytes)
+	if err != nil {
+		h.logger.Printf("[REPLAY] Nearest match search failed: %v", err)
+		return false
+	}
+	if match == nil {
+		return false
+	}
 
+	h.logger.Printf("[REPLAY] No exact match for hash %s, substituting %s (score: %.2f)",
+		shortKey(requestHash), shortKey(match.hash), match.score)
+
+	setSubstituteHeaders(w, match)
+	h.serveRecording(w, r, "REPLAY", match.hash, match.cached)
+
+	duration := time.Since(start)
+	h.logger.Printf("[REPLAY] Completed in %v", duration)
+	return true
+}
+
+// serveRecording writes the next response of the recording stored under key
+// Answers 404 when a recorded sequence is exhausted and configured to end that way
+func (h *Handler) serveRecording(w http.ResponseWriter, r *http.Request, tag, key string, cached *storage.CachedResponse) {
+	resp, position, ok := h.sequences.next(key, cached, h.config.Sequence.End)
+	if !ok {
+		h.logger.Printf("[%s] Recorded sequence exhausted after %d responses | Hash: %s", tag, len(cached.Sequence)+1, shortKey(key))
+		http.Error(w, fmt.Sprintf("recorded sequence exhausted (hash: %s)", key), http.StatusNotFound)
+		return
+	}
+
+	sequence := ""
+	if len(cached.Sequence) > 0 {
+		sequence = fmt.Sprintf(" | Sequence: %d/%d", position, len(cached.Sequence)+1)
+	}
+	h.logger.Printf("[%s] Serving cached response: %s %s | Status: %d | Hash: %s%s",
+		tag, cached.Method, cached.Path, resp.StatusCode, shortKey(key), sequence)
+
+	resp = h.transformResponse(r, tag, cached, resp)
+	if !h.simulateLatency(r, tag, cached, resp) {
+		return
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		h.replayWebSocket(w, r, tag, resp)
+		return
+	}
+	h.writeCached(w, r, resp)
+}
+
+// writeCached writes a cached response to the client
+func (h *Handler) writeCached(w http.ResponseWriter, r *http.Request, cached *storage.Response) {
 	// Check if status code allows a response body
 	// Status codes 1xx, 204 (No Content), and 304 (Not Modified) must not include a body
 	statusAllowsBody := !(cached.StatusCode == 204 || cached.StatusCode == 304 || (cached.StatusCode >= 100 && cached.StatusCode < 200))
@@ -141,6 +427,12 @@ func (h *Handler) handleReplay(w http.ResponseWriter, r *http.Request, requestHa
 	// Set status code
 	w.WriteHeader(cached.StatusCode)
 
+	// Streamed responses are replayed chunk by chunk with their recorded pacing
+	if statusAllowsBody && len(cached.Chunks) > 0 {
+		h.writeChunks(w, r, cached.Chunks, h.config.Stream.Speed)
+		return
+	}
+
 	// Only write body if status code allows it and body is not empty/null
 	bodyStr := string(cached.Body)
 	if statusAllowsBody && len(cached.Body) > 0 && bodyStr != "null" && bodyStr != "" {
@@ -148,18 +440,72 @@ func (h *Handler) handleReplay(w http.ResponseWriter, r *http.Request, requestHa
 			h.logger.Printf("[ERROR] Failed to write response body: %v", err)
 		}
 	}
-
-	duration := time.Since(start)
-	h.logger.Printf("[REPLAY] Completed in %v", duration)
 }
 
 // handleRecord proxies to backend, captures response, saves to cache, and returns to client
 func (h *Handler) handleRecord(w http.ResponseWriter, r *http.Request, requestHash string, bodyBytes []byte, start time.Time) {
-	h.logger.Printf("[RECORD] Proxying to backend: %s", h.config.BackendURL)
+	h.logger.Printf("[RECORD] Proxying to backend: %s", h.routeFor(r).Backend)
+
+	cached, position, err := h.proxyAndSave(w, r, requestHash, bodyBytes, start)
+	if err != nil {
+		h.logger.Printf("[ERROR] Failed to record response: %v", err)
+	} else {
+		h.logger.Printf("[RECORD] Saved response: %s %s | Status: %d | Hash: %s%s",
+			cached.Method, cached.Path, cached.StatusCode, shortKey(requestHash), sequenceSuffix(position))
+	}
+
+	duration := time.Since(start)
+	h.logger.Printf("[RECORD] Completed in %v", duration)
+}
+
+// handleRecordMissing replays recorded responses and fills in missing ones from the backend
+func (h *Handler) handleRecordMissing(w http.ResponseWriter, r *http.Request, requestHash string, bodyBytes []byte, start time.Time) {
+	if h.storage.Exists(requestHash) {
+		cached, err := h.storage.Load(requestHash)
+		if err == nil {
+			h.serveRecording(w, r, "HIT", requestHash, cached)
+
+			duration := time.Since(start)
+			h.logger.Printf("[HIT] Completed in %v", duration)
+			return
+		}
+		h.logger.Printf("[HIT] Failed to load cached response, recording again: %v", err)
+	}
+
+	h.logger.Printf("[MISS] No cached response for hash %s, proxying to backend: %s",
+		shortKey(requestHash), h.routeFor(r).Backend)
+
+	cached, _, err := h.proxyAndSave(w, r, requestHash, bodyBytes, start)
+	if err != nil {
+		h.logger.Printf("[ERROR] Failed to record response: %v", err)
+	} else {
+		h.logger.Printf("[FILL] Saved response: %s %s | Status: %d | Hash: %s",
+			cached.Method, cached.Path, cached.StatusCode, shortKey(requestHash))
+	}
+
+	duration := time.Since(start)
+	h.logger.Printf("[FILL] Completed in %v", duration)
+}
+
+// proxyAndSave proxies the request to the backend while capturing the
+// response, then saves it to the cache under requestHash
+// Returns the captured response and its position in the recorded sequence
+func (h *Handler) proxyAndSave(w http.ResponseWriter, r *http.Request, requestHash string, bodyBytes []byte, start time.Time) (*storage.CachedResponse, int, error) {
+	// Describe the request as the client sent it
+	request := &storage.CachedRequest{
+		URL:        r.URL.RequestURI(),
+		Host:       r.Host,
+		Backend:    h.routeFor(r).Backend,
+		Headers:    hash.RedactHeaders(r.Header, h.hashOpts.Headers),
+		Body:       bodyBytes,
+		RemoteAddr: r.RemoteAddr,
+		Timestamp:  start,
+	}
 
 	// Create a response writer that captures the response
 	capturer := &responseCapturer{
 		ResponseWriter: w,
+		handler:        h,
 		statusCode:     http.StatusOK, // Default status code
 		headers:        make(map[string][]string),
 	}
@@ -168,33 +514,134 @@ func (h *Handler) handleRecord(w http.ResponseWriter, r *http.Request, requestHa
 	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	// Proxy the request
-	h.proxy.ServeHTTP(capturer, r)
+	aborted := h.serveProxy(capturer, r)
+	cached, position, err := h.saveCaptured(w, r, requestHash, request, capturer, aborted, start)
+	if aborted {
+		// The recording is handled, let the server abort the client connection
+		if err != nil {
+			h.logger.Printf("[ERROR] Response not recorded: %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+	return cached, position, err
+}
+
+// saveCaptured saves the response captured by proxyAndSave
+// aborted reports whether the response ended early, which only streams are
+// recorded after
+func (h *Handler) saveCaptured(w http.ResponseWriter, r *http.Request, requestHash string, request *storage.CachedRequest, capturer *responseCapturer, aborted bool, start time.Time) (*storage.CachedResponse, int, error) {
+	// A WebSocket session registered its save when the connection was
+	// hijacked; any other response registers it now
+	if capturer.session != nil {
+		defer h.untrackSession(capturer.session.conn)
+	} else {
+		h.saves.Add(1)
+	}
+	defer h.saves.Done()
+
+	if capturer.proxyErr != nil {
+		return nil, 0, fmt.Errorf("backend unavailable: %w", capturer.proxyErr)
+	}
+	if tw, ok := w.(*truncatingWriter); ok && tw.cut {
+		h.logger.Printf("[FAULT] Truncated response not recorded: %s %s", r.Method, r.URL.Path)
+		return nil, 0, fmt.Errorf("response truncated by an injected fault")
+	}
+	if aborted && !capturer.streaming() {
+		return nil, 0, fmt.Errorf("response aborted mid-body")
+	}
+	request.Duration = storage.Duration(time.Since(start))
 
 	// Capture response after proxying
 	cached := &storage.CachedResponse{
-		Method:     r.Method,
-		Path:       r.URL.Path,
-		StatusCode: capturer.statusCode,
-		Headers:    capturer.headers,
-		Body:       capturer.body,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Response: storage.Response{
+			StatusCode: capturer.statusCode,
+			Headers:    capturer.headers,
+			Body:       capturer.body,
+			Latency:    storage.Duration(capturer.latency(start)),
+			Chunks:     capturer.recordedChunks(),
+		},
+		Request: request,
+	}
+
+	// A WebSocket session has ended; the handshake headers were written
+	// directly to the hijacked connection, so take them from the header map
+	if capturer.session != nil {
+		messages, err := capturer.session.transcript()
+		if err != nil {
+			h.logger.Printf("[WEBSOCKET] Stopped recording session early: %v", err)
+		}
+		cached.StatusCode = http.StatusSwitchingProtocols
+		cached.Headers = w.Header().Clone()
+		cached.Latency = storage.Duration(capturer.session.start.Sub(start))
+		cached.WebSocket = messages
+		h.logger.Printf("[WEBSOCKET] Recorded session: %d messages in %v", len(messages), time.Duration(request.Duration))
 	}
 
 	// Save to cache
-	if err := h.storage.Save(requestHash, cached); err != nil {
-		h.logger.Printf("[ERROR] Failed to save cached response: %v", err)
-	} else {
-		h.logger.Printf("[RECORD] Saved response: %s %s | Status: %d | Hash: %s",
-			cached.Method, cached.Path, cached.StatusCode, requestHash[:16])
+	position, err := h.saveRecording(requestHash, cached)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to save cached response: %w", err)
+	}
+	h.paths.add(requestHash, cached)
+	if aborted {
+		h.logger.Printf("[STREAM] Stream ended early, saved %d bytes received so far | Hash: %s",
+			len(cached.Body), shortKey(requestHash))
 	}
 
-	duration := time.Since(start)
-	h.logger.Printf("[RECORD] Completed in %v", duration)
+	return cached, position, nil
+}
+
+// saveRecording stores a recording under key
+// With sequence recording enabled, a key that was already recorded during this
+// session gets the new response appended to its sequence instead of replaced
+// Returns the position of the response in the sequence
+func (h *Handler) saveRecording(key string, cached *storage.CachedResponse) (int, error) {
+	if !h.config.Sequence.Record {
+		return 1, h.storage.Save(key, cached)
+	}
+
+	// Serialize load-append-save so concurrent responses don't overwrite each other
+	h.sequences.saveMu.Lock()
+	defer h.sequences.saveMu.Unlock()
+
+	if !h.sequences.markRecorded(key) {
+		return 1, h.storage.Save(key, cached)
+	}
+
+	existing, err := h.storage.Load(key)
+	if err != nil {
+		// The earlier recording is gone, start a new sequence
+		return 1, h.storage.Save(key, cached)
+	}
+	existing.Append(cached.Response)
+
+	return len(existing.Sequence) + 1, h.storage.Save(key, existing)
+}
+
+// shortKey abbreviates a storage key for log lines, keeping its namespace
+func shortKey(key string) string {
+	namespace, hash := storage.SplitKey(key)
+	if len(hash) > 16 {
+		hash = hash[:16]
+	}
+	return storage.Key(namespace, hash)
+}
+
+// sequenceSuffix formats a sequence position for log lines, omitting the first position
+func sequenceSuffix(position int) string {
+	if position <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" | Sequence: %d", position)
 }
 
 // handlePassthrough just proxies without recording
 func (h *Handler) handlePassthrough(w http.ResponseWriter, r *http.Request, start time.Time) {
-	h.logger.Printf("[PASSTHROUGH] Proxying to backend: %s", h.config.BackendURL)
-	h.proxy.ServeHTTP(w, r)
+	rt := h.routeFor(r)
+	h.logger.Printf("[PASSTHROUGH] Proxying to backend: %s", rt.Backend)
+	rt.proxy.ServeHTTP(w, r)
 	duration := time.Since(start)
 	h.logger.Printf("[PASSTHROUGH] Completed in %v", duration)
 }
@@ -202,9 +649,22 @@ func (h *Handler) handlePassthrough(w http.ResponseWriter, r *http.Request, star
 // responseCapturer captures the response for recording
 type responseCapturer struct {
 	http.ResponseWriter
+	handler    *Handler
 	statusCode int
 	headers    map[string][]string
 	body       []byte
+	proxyErr   error // Set when the backend could not be reached
+
+	// session records the WebSocket session once the connection is hijacked
+	session *wsRecorder
+
+	// Every response keeps the chunk boundaries seen via Flush, so those that
+	// turn out to be streams replay at their recorded pace
+	eventStream bool // Server-Sent Events, a stream even before it flushes
+	headerAt    time.Time
+	chunks      []storage.Chunk
+	chunkStart  int       // Offset in body of the writes since the last flush
+	pendingAt   time.Time // When the first of those writes arrived
 }
 
 func (rc *responseCapturer) WriteHeader(code int) {
@@ -215,15 +675,34 @@ func (rc *responseCapturer) WriteHeader(code int) {
 		rc.headers[key] = make([]string, len(values))
 		copy(rc.headers[key], values)
 	}
+	rc.headerAt = time.Now()
+	rc.eventStream = isEventStream(header)
 	rc.ResponseWriter.WriteHeader(code)
 }
 
 func (rc *responseCapturer) Write(b []byte) (int, error) {
+	// A write without WriteHeader sends the header first, as in net/http
+	if rc.headerAt.IsZero() {
+		rc.WriteHeader(rc.statusCode)
+	}
 	// Capture body
+	if len(rc.body) == rc.chunkStart {
+		rc.pendingAt = time.Now()
+	}
 	rc.body = append(rc.body, b...)
 	return rc.ResponseWriter.Write(b)
 }
 
+// latency returns how long after start the response header arrived
+// A response that never wrote one, such as a WebSocket session, is timed
+// until now
+func (rc *responseCapturer) latency(start time.Time) time.Duration {
+	if rc.headerAt.IsZero() {
+		return time.Since(start)
+	}
+	return rc.headerAt.Sub(start)
+}
+
 func (rc *responseCapturer) Header() http.Header {
 	return rc.ResponseWriter.Header()
 }
@@ -259,4 +738,3 @@ func stripConditionalHeaders(req *http.Request) bool {
 
 	return stripped
 }
-
@@ -0,0 +1,286 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// testConfig returns the configuration Load produces without any settings,
+// in mode and proxying to backend
+func testConfig(mode config.Mode, backend string) *config.Config {
+	return &config.Config{
+		Mode:       mode,
+		BackendURL: backend,
+		Port:       3000,
+		Storage:    storage.BackendMemory,
+		Hash: config.HashConfig{
+			Strategy: "default",
+			Query:    config.QueryConfig{Enabled: true, Sort: true, Ignore: []string{"_"}, Repeated: "preserve"},
+			Body:     config.BodyConfig{Mode: "raw"},
+		},
+		Fuzzy:     config.FuzzyConfig{Threshold: 0.5},
+		Sequence:  config.SequenceConfig{End: config.SequenceEndLast},
+		Stream:    config.StreamConfig{Speed: 1},
+		WebSocket: config.WebSocketConfig{Replay: config.WebSocketReplayTimed},
+		Latency:   config.LatencyConfig{Delay: config.Delay{Mode: config.LatencyOff}},
+	}
+}
+
+// testBackend answers every request with its method, path, query and body,
+// and counts the requests it receives
+type testBackend struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	t.Helper()
+	b := &testBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("X-Backend", "a")
+		w.Header().Add("X-Backend", "b")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// newTestHandler creates a handler serving cfg from st
+func newTestHandler(t *testing.T, cfg *config.Config, st storage.Store) *Handler {
+	t.Helper()
+	h, err := New(cfg, st, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+// do sends a request through h and returns the response
+func do(h http.Handler, method, target, body string) *http.Response {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w.Result()
+}
+
+// readBody returns the body of resp
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	return string(body)
+}
+
+func TestRecordReplay(t *testing.T) {
+	backend := newTestBackend(t)
+	st, err := storage.Open(storage.BackendFile, t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	requests := []struct {
+		method, target, body string
+		status               int
+	}{
+		{"GET", "/api/users?page=1", "", http.StatusCreated},
+		{"POST", "/api/search", `{"q":"chameleon"}`, http.StatusCreated},
+		{"GET", "/missing", "", http.StatusNotFound},
+	}
+
+	recorder := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+	recorded := make([]string, len(requests))
+	for i, req := range requests {
+		resp := do(recorder, req.method, req.target, req.body)
+		recorded[i] = readBody(t, resp)
+		if resp.StatusCode != req.status {
+			t.Errorf("record %s %s: status %d, want %d", req.method, req.target, resp.StatusCode, req.status)
+		}
+	}
+	recorder.Wait()
+	if n := backend.requests.Load(); n != int32(len(requests)) {
+		t.Fatalf("backend received %d requests while recording, want %d", n, len(requests))
+	}
+
+	// A new handler replays from the same store, without the backend
+	backend.Close()
+	replayer := newTestHandler(t, testConfig(config.ModeReplay, backend.URL), st)
+	for i, req := range requests {
+		resp := do(replayer, req.method, req.target, req.body)
+		if resp.StatusCode != req.status {
+			t.Errorf("replay %s %s: status %d, want %d", req.method, req.target, resp.StatusCode, req.status)
+		}
+		if body := readBody(t, resp); body != recorded[i] {
+			t.Errorf("replay %s %s: body %q, want %q", req.method, req.target, body, recorded[i])
+		}
+		if got := resp.Header.Values("X-Backend"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("replay %s %s: X-Backend = %v, want [a b]", req.method, req.target, got)
+		}
+	}
+
+	// Equivalent queries share a recording, others don't
+	if resp := do(replayer, "GET", "/api/users?_=123&page=1", ""); resp.StatusCode != http.StatusCreated {
+		t.Errorf("replay with a cache buster: status %d, want the recording", resp.StatusCode)
+	}
+	for _, target := range []string{"/api/users?page=2", "/api/users"} {
+		if resp := do(replayer, "GET", target, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("replay of unrecorded %s: status %d, want 404", target, resp.StatusCode)
+		}
+	}
+	if resp := do(replayer, "POST", "/api/search", `{"q":"other"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("replay with another body: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestRecordedRequest(t *testing.T) {
+	backend := newTestBackend(t)
+	st, _ := storage.Open(storage.BackendMemory, "")
+	h := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+
+	r := httptest.NewRequest("PUT", "/api/items/7?v=2", strings.NewReader(`{"b": 1,  "a":2}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Accept", "text/plain")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	h.Wait()
+
+	keys, err := st.List()
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("List = %v, %v, want one recording", keys, err)
+	}
+	cached, err := st.Load(keys[0])
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	req := cached.Request
+	if req == nil {
+		t.Fatalf("recording has no request")
+	}
+	if req.URL != "/api/items/7?v=2" || string(req.Body) != `{"b": 1,  "a":2}` {
+		t.Errorf("recorded request %s with body %q, want the request as sent", req.URL, req.Body)
+	}
+	if got := req.Headers["Authorization"]; len(got) != 1 || !strings.HasPrefix(got[0], "sha256:") {
+		t.Errorf("recorded Authorization = %v, want its digest", got)
+	}
+	if got := req.Headers["Accept"]; len(got) != 1 || got[0] != "text/plain" {
+		t.Errorf("recorded Accept = %v, want it as sent", got)
+	}
+
+	// The stored request hashes to the key it was recorded under
+	again, body, err := cached.HTTPRequest()
+	if err != nil {
+		t.Fatalf("HTTPRequest: %v", err)
+	}
+	if key, err := h.Key(again, body); err != nil || key != keys[0] {
+		t.Errorf("key of the stored request = %s, %v, want %s", key, err, keys[0])
+	}
+}
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		mode config.Mode
+		// Statuses and backend request counts after a miss and then the same request again
+		statuses [2]int
+		requests [2]int32
+		recorded bool
+	}{
+		{config.ModeRecord, [2]int{201, 201}, [2]int32{1, 2}, true},
+		{config.ModeReplay, [2]int{404, 404}, [2]int32{0, 0}, false},
+		{config.ModeRecordMissing, [2]int{201, 201}, [2]int32{1, 1}, true},
+		{config.ModeReplayPassthrough, [2]int{201, 201}, [2]int32{1, 2}, false},
+		{config.ModePassthrough, [2]int{201, 201}, [2]int32{1, 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			backend := newTestBackend(t)
+			st, _ := storage.Open(storage.BackendMemory, "")
+			h := newTestHandler(t, testConfig(tt.mode, backend.URL), st)
+
+			for i := range tt.statuses {
+				resp := do(h, "GET", "/api/users", "")
+				h.Wait()
+				if resp.StatusCode != tt.statuses[i] {
+					t.Errorf("request %d: status %d, want %d", i+1, resp.StatusCode, tt.statuses[i])
+				}
+				if n := backend.requests.Load(); n != tt.requests[i] {
+					t.Errorf("after request %d the backend received %d requests, want %d", i+1, n, tt.requests[i])
+				}
+			}
+			keys, _ := st.List()
+			if recorded := len(keys) > 0; recorded != tt.recorded {
+				t.Errorf("recorded = %v, want %v", recorded, tt.recorded)
+			}
+		})
+	}
+}
+
+func TestResponseCapturerWithoutWriteHeader(t *testing.T) {
+	start := time.Now()
+	rc := &responseCapturer{ResponseWriter: httptest.NewRecorder(), statusCode: http.StatusOK, headers: make(map[string][]string)}
+	if latency := rc.latency(start); latency < 0 {
+		t.Errorf("latency before the header = %v, want the time since start", latency)
+	}
+
+	rc.Header().Set("Content-Type", "text/plain")
+	io.WriteString(rc, "implicit")
+	if rc.headerAt.IsZero() || rc.headers["Content-Type"] == nil {
+		t.Errorf("Write without WriteHeader captured no header: %v", rc.headers)
+	}
+	if latency := rc.latency(start); latency < 0 || latency > time.Since(start) {
+		t.Errorf("latency = %v, want the time until the write", latency)
+	}
+}
+
+func TestReload(t *testing.T) {
+	backend := newCountingBackend(t)
+	st := storage.NewMemoryStore()
+	cfg := testConfig(config.ModeRecord, backend.URL)
+	cfg.Sequence.Record = true
+	h := newTestHandler(t, cfg, st)
+	for i := 0; i < 2; i++ {
+		do(h, "GET", "/api/job", "")
+		h.Wait()
+	}
+
+	replay := testConfig(config.ModeReplay, backend.URL)
+	if err := h.Reload(replay); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := readBody(t, do(h, "GET", "/api/job", "")); got != "1" {
+		t.Errorf("after reloading into replay: body %s, want the first recorded response", got)
+	}
+
+	// An invalid configuration leaves the previous one in effect
+	invalid := testConfig(config.ModeRecord, backend.URL)
+	invalid.Faults = []config.FaultRule{{Match: "", Action: config.FaultDrop, Probability: 1}}
+	if err := h.Reload(invalid); err == nil {
+		t.Fatalf("Reload accepted an invalid configuration")
+	}
+	if got := readBody(t, do(h, "GET", "/api/job", "")); got != "2" {
+		t.Errorf("after a failed reload: body %s, want the next recorded response", got)
+	}
+
+	// Reloading starts sequences over
+	if err := h.Reload(replay); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := readBody(t, do(h, "GET", "/api/job", "")); got != "1" {
+		t.Errorf("after reloading again: body %s, want the first recorded response", got)
+	}
+}
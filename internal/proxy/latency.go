@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// latencyRule is a compiled config.LatencyRule
+type latencyRule struct {
+	pattern *route.Pattern
+	delay   config.Delay
+}
+
+// newLatencyRules compiles the per-route latency rules
+func newLatencyRules(rules []config.LatencyRule) ([]latencyRule, error) {
+	compiled := make([]latencyRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := route.Parse(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latency rule: %w", err)
+		}
+		compiled = append(compiled, latencyRule{pattern: pattern, delay: rule.Delay})
+	}
+	return compiled, nil
+}
+
+// latencyFor returns how long to hold back resp, a recorded response for r
+func (h *Handler) latencyFor(r *http.Request, cached *storage.CachedResponse, resp *storage.Response) time.Duration {
+	delay := h.config.Latency.Delay
+	for _, rule := range h.latencyRules {
+		if rule.pattern.MatchRequest(r) {
+			delay = rule.delay
+			break
+		}
+	}
+
+	switch delay.Mode {
+	case config.LatencyRecorded:
+		if resp.Latency > 0 {
+			return time.Duration(resp.Latency)
+		}
+		// Recordings made before latencies were stored only have the full round trip
+		if cached.Request != nil {
+			return time.Duration(cached.Request.Duration)
+		}
+	case config.LatencyFixed:
+		return delay.Fixed
+	case config.LatencyRandom:
+		if delay.Max > delay.Min {
+			return delay.Min + time.Duration(rand.Int63n(int64(delay.Max-delay.Min)))
+		}
+		return delay.Min
+	}
+	return 0
+}
+
+// simulateLatency waits out the configured latency before a recorded response
+// is written. Returns false if the client went away in the meantime
+func (h *Handler) simulateLatency(r *http.Request, tag string, cached *storage.CachedResponse, resp *storage.Response) bool {
+	latency := h.latencyFor(r, cached, resp)
+	if latency <= 0 {
+		return true
+	}
+
+	h.logger.Printf("[%s] Simulating latency: %v", tag, latency)
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-r.Context().Done():
+		h.logger.Printf("[%s] Client disconnected during simulated latency", tag)
+		return false
+	case <-timer.C:
+		return true
+	}
+}
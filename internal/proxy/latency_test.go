@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func TestLatencyFor(t *testing.T) {
+	withLatency := &storage.CachedResponse{
+		Response: storage.Response{Latency: storage.Duration(40 * time.Millisecond)},
+		Request:  &storage.CachedRequest{Duration: storage.Duration(50 * time.Millisecond)},
+	}
+	// Recorded before latencies were stored
+	withDuration := &storage.CachedResponse{Request: &storage.CachedRequest{Duration: storage.Duration(50 * time.Millisecond)}}
+	withNothing := &storage.CachedResponse{}
+
+	rules := []config.LatencyRule{
+		{Match: "GET /slow/*", Delay: config.Delay{Mode: config.LatencyFixed, Fixed: time.Second}},
+		{Match: "/slow/*", Delay: config.Delay{Mode: config.LatencyOff}},
+	}
+	tests := []struct {
+		name   string
+		delay  config.Delay
+		method string
+		path   string
+		cached *storage.CachedResponse
+		want   time.Duration
+	}{
+		{"off", config.Delay{Mode: config.LatencyOff}, "GET", "/", withLatency, 0},
+		{"recorded", config.Delay{Mode: config.LatencyRecorded}, "GET", "/", withLatency, 40 * time.Millisecond},
+		{"recorded round trip", config.Delay{Mode: config.LatencyRecorded}, "GET", "/", withDuration, 50 * time.Millisecond},
+		{"nothing recorded", config.Delay{Mode: config.LatencyRecorded}, "GET", "/", withNothing, 0},
+		{"fixed", config.Delay{Mode: config.LatencyFixed, Fixed: 200 * time.Millisecond}, "GET", "/", withLatency, 200 * time.Millisecond},
+		{"empty random range", config.Delay{Mode: config.LatencyRandom, Min: 300 * time.Millisecond, Max: 300 * time.Millisecond}, "GET", "/", withLatency, 300 * time.Millisecond},
+		{"rule", config.Delay{Mode: config.LatencyRecorded}, "GET", "/slow/report", withLatency, time.Second},
+		{"first matching rule wins", config.Delay{Mode: config.LatencyRecorded}, "POST", "/slow/report", withLatency, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(config.ModeReplay, "http://localhost:1")
+			cfg.Latency = config.LatencyConfig{Delay: tt.delay, Rules: rules}
+			h := newTestHandler(t, cfg, storage.NewMemoryStore())
+
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if got := h.latencyFor(r, tt.cached, &tt.cached.Response); got != tt.want {
+				t.Errorf("latencyFor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyRandom(t *testing.T) {
+	cfg := testConfig(config.ModeReplay, "http://localhost:1")
+	cfg.Latency.Delay = config.Delay{Mode: config.LatencyRandom, Min: 100 * time.Millisecond, Max: 200 * time.Millisecond}
+	h := newTestHandler(t, cfg, storage.NewMemoryStore())
+
+	cached := &storage.CachedResponse{}
+	r := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 100; i++ {
+		if got := h.latencyFor(r, cached, &cached.Response); got < 100*time.Millisecond || got >= 200*time.Millisecond {
+			t.Fatalf("latencyFor = %v, want within [100ms, 200ms)", got)
+		}
+	}
+}
+
+func TestSimulateLatencyClientGone(t *testing.T) {
+	cfg := testConfig(config.ModeReplay, "http://localhost:1")
+	cfg.Latency.Delay = config.Delay{Mode: config.LatencyFixed, Fixed: time.Hour}
+	h := newTestHandler(t, cfg, storage.NewMemoryStore())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	cached := &storage.CachedResponse{Response: storage.Response{StatusCode: http.StatusOK}}
+	if h.simulateLatency(r, "REPLAY", cached, &cached.Response) {
+		t.Errorf("simulateLatency = true for a client that went away")
+	}
+}
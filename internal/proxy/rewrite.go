@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/route"
+)
+
+// rewriteRule is a compiled config.RewriteRule
+type rewriteRule struct {
+	config.RewriteRule
+	pattern *route.Pattern
+	pathRe  *regexp.Regexp // Set for regular expression path rewrites
+}
+
+// newRewriteRules compiles the request rewrite rules
+func newRewriteRules(rules []config.RewriteRule) ([]*rewriteRule, error) {
+	compiled := make([]*rewriteRule, 0, len(rules))
+	for i, rule := range rules {
+		pattern, err := route.Parse(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrites[%d]: %w", i, err)
+		}
+		rr := &rewriteRule{RewriteRule: rule, pattern: pattern}
+		if rule.Path != nil && rule.Path.Regex {
+			if rr.pathRe, err = regexp.Compile(rule.Path.From); err != nil {
+				return nil, fmt.Errorf("invalid rewrites[%d]: %w", i, err)
+			}
+		}
+		compiled = append(compiled, rr)
+	}
+	return compiled, nil
+}
+
+// rewriteRequest applies every rewrite rule matching req, in order, before it
+// is forwarded to the backend. Rules are matched against the request as the
+// client sent it, so a rewritten path doesn't trigger other rules
+func (h *Handler) rewriteRequest(req *http.Request) {
+	method, path, uri := req.Method, req.URL.Path, req.URL.RequestURI()
+
+	applied := 0
+	for _, rule := range h.rewrites {
+		if !rule.pattern.Match(method, path) {
+			continue
+		}
+		applied++
+
+		if rule.Path != nil {
+			req.URL.Path = rule.rewritePath(req.URL.Path)
+			req.URL.RawPath = ""
+		}
+		if !rule.Query.Empty() {
+			query := req.URL.Query()
+			applyEdits(url.Values(query), rule.Query, func(name string) string { return name })
+			req.URL.RawQuery = query.Encode()
+		}
+		if !rule.Headers.Empty() {
+			applyEdits(url.Values(req.Header), rule.Headers, http.CanonicalHeaderKey)
+		}
+	}
+
+	if applied > 0 {
+		h.logger.Printf("[REWRITE] Applied %d rewrite rules: %s %s -> %s", applied, method, uri, req.URL.RequestURI())
+	}
+}
+
+// rewritePath returns path with the rule's path rewrite applied
+func (rule *rewriteRule) rewritePath(path string) string {
+	if rule.pathRe != nil {
+		return rule.pathRe.ReplaceAllString(path, rule.Path.To)
+	}
+	// Match whole segments only, so /api/v1 doesn't rewrite /api/v10
+	rest, ok := route.CutPathPrefix(path, rule.Path.From)
+	switch {
+	case !ok:
+		return path
+	case rest == "":
+		return rule.Path.To
+	default:
+		return strings.TrimSuffix(rule.Path.To, "/") + rest
+	}
+}
+
+// applyEdits changes a header or query value map; canonical normalizes names
+func applyEdits(values url.Values, edits config.ValueEdits, canonical func(string) string) {
+	for _, name := range edits.Delete {
+		delete(values, canonical(name))
+	}
+	for name, value := range edits.Set {
+		values[canonical(name)] = []string{value}
+	}
+	for name, value := range edits.Add {
+		key := canonical(name)
+		values[key] = append(values[key], value)
+	}
+}
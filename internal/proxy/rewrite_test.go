@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func TestRewritePath(t *testing.T) {
+	tests := []struct {
+		from, to string
+		regex    bool
+		path     string
+		want     string
+	}{
+		{"/api/v1", "/v2", false, "/api/v1", "/v2"},
+		{"/api/v1", "/v2", false, "/api/v1/users", "/v2/users"},
+		{"/api/v1/", "/v2/", false, "/api/v1/users", "/v2/users"},
+		{"/api/v1", "/v2", false, "/api/v10/users", "/api/v10/users"},
+		{"/api", "/", false, "/api/users", "/users"},
+		{"/", "/backend", false, "/users", "/backend/users"},
+		{`^/users/(\d+)$`, "/people/$1", true, "/users/42", "/people/42"},
+	}
+	for _, tt := range tests {
+		rules, err := newRewriteRules([]config.RewriteRule{{Match: "*", Path: &config.PathRewrite{From: tt.from, To: tt.to, Regex: tt.regex}}})
+		if err != nil {
+			t.Fatalf("newRewriteRules: %v", err)
+		}
+		if got := rules[0].rewritePath(tt.path); got != tt.want {
+			t.Errorf("rewriting %s from %s to %s = %s, want %s", tt.path, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteRequest(t *testing.T) {
+	// The backend echoes the request as it arrived
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s token=%v debug=%v", r.URL.RequestURI(), r.Header.Values("X-Token"), r.Header.Values("X-Debug"))
+	}))
+	defer backend.Close()
+
+	cfg := testConfig(config.ModePassthrough, backend.URL)
+	cfg.Rewrites = []config.RewriteRule{
+		{
+			Match:   "/api/*",
+			Path:    &config.PathRewrite{From: "/api", To: "/v2"},
+			Query:   config.ValueEdits{Delete: []string{"debug"}, Set: map[string]string{"page": "1"}},
+			Headers: config.ValueEdits{Set: map[string]string{"x-token": "secret"}, Delete: []string{"X-Debug"}},
+		},
+		// Matched against the request as sent, so it doesn't see the rewritten path
+		{Match: "/v2/*", Headers: config.ValueEdits{Add: map[string]string{"X-Token": "unexpected"}}},
+	}
+	h := newTestHandler(t, cfg, storage.NewMemoryStore())
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/users?debug=1&page=3", "/v2/users?page=1 token=[secret] debug=[]"},
+		{"/other?debug=1", "/other?debug=1 token=[] debug=[1]"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Header.Set("X-Debug", "1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%s reached the backend as %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/websocket"
+)
+
+// backendRoute is a compiled config.BackendRoute with its reverse proxy
+// The default route has no pattern and an empty name, so its recordings
+// live at the top of the storage directory
+type backendRoute struct {
+	config.BackendRoute
+	pattern *route.Pattern
+	proxy   *httputil.ReverseProxy
+}
+
+// newBackendRoutes compiles the backend routes and the default route serving
+// requests matched by none of them
+func (h *Handler) newBackendRoutes(cfg *config.Config) ([]*backendRoute, *backendRoute, error) {
+	routes := make([]*backendRoute, 0, len(cfg.Routes))
+	for i, rt := range cfg.Routes {
+		pattern, err := route.Parse(rt.Match)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid routes[%d]: %w", i, err)
+		}
+		proxy, err := h.newReverseProxy(rt.Backend)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid routes[%d]: %w", i, err)
+		}
+		routes = append(routes, &backendRoute{BackendRoute: rt, pattern: pattern, proxy: proxy})
+	}
+
+	proxy, err := h.newReverseProxy(cfg.BackendURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	fallback := &backendRoute{BackendRoute: config.BackendRoute{Backend: cfg.BackendURL}, proxy: proxy}
+
+	return routes, fallback, nil
+}
+
+// routeFor returns the first route matching r, or the default route
+func (h *Handler) routeFor(r *http.Request) *backendRoute {
+	for _, rt := range h.routes {
+		if rt.matchHost(r.Host) && rt.pattern.MatchRequest(r) {
+			return rt
+		}
+	}
+	return h.fallback
+}
+
+// matchHost reports whether a request addressed to host belongs to the route
+// The port is only compared if the route's host names one
+func (rt *backendRoute) matchHost(host string) bool {
+	if rt.Host == "" {
+		return true
+	}
+	if !strings.Contains(rt.Host, ":") {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	return strings.EqualFold(host, rt.Host)
+}
+
+// newReverseProxy builds the reverse proxy forwarding requests to backend
+func (h *Handler) newReverseProxy(backend string) (*httputil.ReverseProxy, error) {
+	backendURL, err := url.Parse(backend)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend URL: %w", err)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(backendURL)
+
+	// Customize the proxy director
+	originalDirector := proxy.Director
+	mode := h.config.Mode
+	proxy.Director = func(req *http.Request) {
+		// Rewrite rules see the path the client asked for, before the backend's base path is added
+		h.rewriteRequest(req)
+		originalDirector(req)
+		req.Host = backendURL.Host
+
+		// In recording modes, strip conditional headers to force full responses
+		// This prevents 304 (Not Modified) responses and ensures we get the actual resource
+		if mode.Records() {
+			stripped := stripConditionalHeaders(req)
+			if stripped {
+				h.logger.Printf("[RECORD] Stripped conditional headers to force full response")
+			}
+
+			// Compressed WebSocket frames can't be recorded, so don't let the backend negotiate them
+			if websocket.IsUpgrade(req) {
+				req.Header.Del("Sec-WebSocket-Extensions")
+			}
+		}
+	}
+
+	// Don't record gateway errors produced by the proxy itself when the backend is unreachable
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		h.logger.Printf("[ERROR] Backend request failed: %v", err)
+		if capturer, ok := w.(*responseCapturer); ok {
+			capturer.proxyErr = err
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	return proxy, nil
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func TestRoutes(t *testing.T) {
+	auth, cdn, fallback := newTestBackend(t), newTestBackend(t), newTestBackend(t)
+	cfg := testConfig(config.ModeRecord, fallback.URL)
+	cfg.Routes = []config.BackendRoute{
+		{Name: "auth", Match: "/auth/*", Backend: auth.URL},
+		{Name: "cdn", Match: "*", Host: "cdn.example.test", Backend: cdn.URL},
+	}
+	st := storage.NewMemoryStore()
+	h := newTestHandler(t, cfg, st)
+
+	tests := []struct {
+		host, target string
+		backend      *testBackend
+		namespace    string
+	}{
+		{"example.test", "/auth/login", auth, "auth"},
+		{"example.test", "/authors", fallback, ""},
+		{"cdn.example.test:8080", "/logo.png", cdn, "cdn"},
+		{"CDN.example.test", "/auth/login", auth, "auth"},
+		{"example.test", "/logo.png", fallback, ""},
+	}
+	for _, tt := range tests {
+		before := tt.backend.requests.Load()
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		h.Wait()
+		if tt.backend.requests.Load() != before+1 {
+			t.Errorf("%s%s was not sent to the %q route", tt.host, tt.target, tt.namespace)
+		}
+
+		key, err := h.Key(r, nil)
+		if err != nil {
+			t.Fatalf("Key: %v", err)
+		}
+		if namespace, _ := storage.SplitKey(key); namespace != tt.namespace {
+			t.Errorf("%s%s keyed as %s, want namespace %q", tt.host, tt.target, key, tt.namespace)
+		}
+		if _, err := st.Load(key); err != nil {
+			t.Errorf("%s%s not recorded under %s: %v", tt.host, tt.target, key, err)
+		}
+	}
+}
+
+func TestRoutesReplayWithinNamespace(t *testing.T) {
+	backend := newTestBackend(t)
+	st := storage.NewMemoryStore()
+	recorder := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+	do(recorder, "GET", "/auth/login", "")
+	recorder.Wait()
+
+	// Recordings of the default route don't answer for a route added later
+	cfg := testConfig(config.ModeReplay, backend.URL)
+	cfg.Routes = []config.BackendRoute{{Name: "auth", Match: "/auth/*", Backend: backend.URL}}
+	replayer := newTestHandler(t, cfg, st)
+	if resp := do(replayer, "GET", "/auth/login", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("replay through the auth route: status %d, want 404", resp.StatusCode)
+	}
+
+	keys, _ := st.List()
+	if len(keys) != 1 || strings.Contains(keys[0], "/") {
+		t.Errorf("List = %v, want one recording of the default route", keys)
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// sequencer tracks where each recording is in its response sequence, and
+// which keys have already been recorded during this session
+type sequencer struct {
+	mu        sync.Mutex
+	positions map[string]int
+	recorded  map[string]bool
+
+	// saveMu serializes appending responses to recorded sequences
+	saveMu sync.Mutex
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{
+		positions: make(map[string]int),
+		recorded:  make(map[string]bool),
+	}
+}
+
+// next returns the response to replay for the recording stored under key,
+// along with its 1-based position in the sequence
+// ok is false when the sequence is exhausted and the end behavior is "404"
+func (s *sequencer) next(key string, cached *storage.CachedResponse, end string) (resp *storage.Response, position int, ok bool) {
+	// Single responses are served every time, regardless of the end behavior
+	if len(cached.Sequence) == 0 {
+		return &cached.Response, 1, true
+	}
+
+	s.mu.Lock()
+	i := s.positions[key]
+	s.positions[key]++
+	s.mu.Unlock()
+
+	responses := cached.Responses()
+	if i >= len(responses) {
+		switch end {
+		case config.SequenceEndLoop:
+			i %= len(responses)
+		case config.SequenceEndNotFound:
+			return nil, 0, false
+		default:
+			i = len(responses) - 1
+		}
+	}
+
+	return &responses[i], i + 1, true
+}
+
+// reset starts every sequence over from its first response
+func (s *sequencer) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions = make(map[string]int)
+}
+
+// serveSequences answers requests to reset the replay positions of sequences
+func (h *Handler) serveSequences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.sequences.reset()
+	h.logger.Printf("[SEQUENCE] Replay positions reset")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markRecorded records that key was saved during this session and reports
+// whether it had already been saved before
+func (s *sequencer) markRecorded(key string) (seen bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen = s.recorded[key]
+	s.recorded[key] = true
+	return seen
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// newCountingBackend answers the nth request with "n"
+func newCountingBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	var n atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, n.Add(1))
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestSequence(t *testing.T) {
+	backend := newCountingBackend(t)
+	st := storage.NewMemoryStore()
+	cfg := testConfig(config.ModeRecord, backend.URL)
+	cfg.Sequence.Record = true
+	recorder := newTestHandler(t, cfg, st)
+	for i := 0; i < 3; i++ {
+		do(recorder, "GET", "/api/job", "")
+		recorder.Wait()
+	}
+
+	tests := []struct {
+		end  string
+		want []string // Bodies of five replays, "404" for a 404 answer
+	}{
+		{config.SequenceEndLast, []string{"1", "2", "3", "3", "3"}},
+		{config.SequenceEndLoop, []string{"1", "2", "3", "1", "2"}},
+		{config.SequenceEndNotFound, []string{"1", "2", "3", "404", "404"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.end, func(t *testing.T) {
+			cfg := testConfig(config.ModeReplay, backend.URL)
+			cfg.Sequence.End = tt.end
+			replayer := newTestHandler(t, cfg, st)
+
+			replay := func() string {
+				resp := do(replayer, "GET", "/api/job", "")
+				if resp.StatusCode == http.StatusNotFound {
+					return "404"
+				}
+				return readBody(t, resp)
+			}
+			for i, want := range tt.want {
+				if got := replay(); got != want {
+					t.Errorf("replay %d = %s, want %s", i+1, got, want)
+				}
+			}
+
+			// The admin API starts every sequence over
+			if resp := do(replayer, "DELETE", adminSequences, ""); resp.StatusCode != http.StatusNoContent {
+				t.Fatalf("DELETE %s: status %d, want 204", adminSequences, resp.StatusCode)
+			}
+			if got := replay(); got != tt.want[0] {
+				t.Errorf("replay after reset = %s, want %s", got, tt.want[0])
+			}
+		})
+	}
+}
+
+func TestSequenceRecordOff(t *testing.T) {
+	backend := newCountingBackend(t)
+
+	// Without sequence recording, each response overwrites the one before
+	st := storage.NewMemoryStore()
+	recorder := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+	for i := 0; i < 2; i++ {
+		do(recorder, "GET", "/api/job", "")
+		recorder.Wait()
+	}
+
+	replayer := newTestHandler(t, testConfig(config.ModeReplay, backend.URL), st)
+	for i := 0; i < 2; i++ {
+		if got := readBody(t, do(replayer, "GET", "/api/job", "")); got != "2" {
+			t.Errorf("replay %d = %s, want the last recorded response", i+1, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// isEventStream reports whether a response carries Server-Sent Events
+func isEventStream(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// streaming reports whether the captured response is a stream: Server-Sent
+// Events, or a response the backend flushed more than once
+// A body without a known length that arrived in one piece is not
+func (rc *responseCapturer) streaming() bool {
+	return rc.eventStream || len(rc.chunks) > 1
+}
+
+// Flush implements http.Flusher so streamed responses reach the client as
+// they arrive instead of being held back until the backend finishes
+func (rc *responseCapturer) Flush() {
+	rc.endChunk()
+	// The underlying writer may not support flushing; nothing more can be done then
+	_ = http.NewResponseController(rc.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (rc *responseCapturer) Unwrap() http.ResponseWriter {
+	return rc.ResponseWriter
+}
+
+// endChunk closes the chunk made of the writes since the last flush
+func (rc *responseCapturer) endChunk() {
+	if len(rc.body) == rc.chunkStart {
+		return
+	}
+	rc.chunks = append(rc.chunks, storage.NewChunk(rc.pendingAt.Sub(rc.headerAt), rc.body[rc.chunkStart:]))
+	rc.chunkStart = len(rc.body)
+}
+
+// recordedChunks returns the chunk boundaries worth keeping
+// A response that arrived in one piece is just a body, so it has none
+func (rc *responseCapturer) recordedChunks() []storage.Chunk {
+	rc.endChunk()
+	if len(rc.chunks) < 2 {
+		return nil
+	}
+	return rc.chunks
+}
+
+// writeChunks replays a streamed response body, flushing each chunk at its
+// recorded offset divided by speed (0 sends chunks without delay)
+// Stops early if the client goes away
+func (h *Handler) writeChunks(w http.ResponseWriter, r *http.Request, chunks []storage.Chunk, speed float64) {
+	rc := http.NewResponseController(w)
+	started := time.Now()
+
+	for _, chunk := range chunks {
+		if speed > 0 {
+			due := started.Add(time.Duration(float64(chunk.Offset) / speed))
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				h.logger.Printf("[REPLAY] Client disconnected during stream replay")
+				return
+			case <-timer.C:
+			}
+		}
+
+		if _, err := w.Write(chunk.Bytes()); err != nil {
+			h.logger.Printf("[ERROR] Failed to write response chunk: %v", err)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			h.logger.Printf("[ERROR] Failed to flush response chunk: %v", err)
+			return
+		}
+	}
+}
+
+// serveProxy runs the reverse proxy and reports whether it aborted the
+// response mid-stream, which happens when the client or the backend goes away
+// while the body is being copied
+func (h *Handler) serveProxy(w http.ResponseWriter, r *http.Request) (aborted bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			if rec != http.ErrAbortHandler {
+				panic(rec)
+			}
+			aborted = true
+		}
+	}()
+
+	h.routeFor(r).proxy.ServeHTTP(w, r)
+	return false
+}
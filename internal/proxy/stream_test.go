@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// newEventBackend answers /events with three Server-Sent Events sent gap
+// apart, and anything else with a plain body written in one piece
+func newEventBackend(t *testing.T, gap time.Duration) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events" {
+			fmt.Fprint(w, "plain")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 1; i <= 3; i++ {
+			if i > 1 {
+				time.Sleep(gap)
+			}
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestStreamRecordReplay(t *testing.T) {
+	const gap = 30 * time.Millisecond
+	const events = "data: 1\n\ndata: 2\n\ndata: 3\n\n"
+	backend := newEventBackend(t, gap)
+	st := storage.NewMemoryStore()
+	recorder := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+	if body := readBody(t, do(recorder, "GET", "/events", "")); body != events {
+		t.Fatalf("recorded body %q, want %q", body, events)
+	}
+	do(recorder, "GET", "/plain", "")
+	recorder.Wait()
+
+	replayer := newTestHandler(t, testConfig(config.ModeReplay, backend.URL), st)
+	key, err := replayer.Key(httptest.NewRequest("GET", "/events", nil), nil)
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+	cached, err := st.Load(key)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cached.Chunks) != 3 {
+		t.Fatalf("recorded %d chunks, want one per event", len(cached.Chunks))
+	}
+	for i, chunk := range cached.Chunks {
+		if want := fmt.Sprintf("data: %d\n\n", i+1); string(chunk.Bytes()) != want {
+			t.Errorf("chunk %d = %q, want %q", i, chunk.Bytes(), want)
+		}
+		if i > 0 && chunk.Offset < cached.Chunks[i-1].Offset+storage.Duration(gap/2) {
+			t.Errorf("chunk %d offset %v, want about %v after chunk %d at %v", i, chunk.Offset, gap, i-1, cached.Chunks[i-1].Offset)
+		}
+	}
+
+	// A body written in one piece is not a stream
+	key, _ = replayer.Key(httptest.NewRequest("GET", "/plain", nil), nil)
+	if plain, err := st.Load(key); err != nil || len(plain.Chunks) != 0 {
+		t.Errorf("plain response recorded with chunks %v (%v), want none", plain.Chunks, err)
+	}
+
+	// Replay keeps the pacing at speed 1 and drops it at speed 0
+	for _, speed := range []float64{1, 0} {
+		cfg := testConfig(config.ModeReplay, backend.URL)
+		cfg.Stream.Speed = speed
+		h := newTestHandler(t, cfg, st)
+		start := time.Now()
+		body := readBody(t, do(h, "GET", "/events", ""))
+		elapsed := time.Since(start)
+		if body != events {
+			t.Errorf("speed %v: replayed body %q, want %q", speed, body, events)
+		}
+		if paced := elapsed >= 2*gap*9/10; paced != (speed > 0) {
+			t.Errorf("speed %v: replay took %v, want paced = %v", speed, elapsed, speed > 0)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/storage"
+	"github.com/yourusername/chameleon/internal/transform"
+)
+
+// newPipeline builds the response transform pipeline from the transform rules
+func newPipeline(rules []config.TransformRule) (*transform.Pipeline, error) {
+	pipeline := &transform.Pipeline{}
+	for i, rule := range rules {
+		pattern, err := route.Parse(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transforms[%d]: %w", i, err)
+		}
+		t, err := transform.New(rule.Type, rule.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transforms[%d]: %w", i, err)
+		}
+		pipeline.Rules = append(pipeline.Rules, transform.Rule{Name: rule.Type, Pattern: pattern, Transformer: t})
+	}
+	return pipeline, nil
+}
+
+// transformResponse runs a recorded response through the transform pipeline
+// If a transform fails the response is served as recorded
+func (h *Handler) transformResponse(r *http.Request, tag string, cached *storage.CachedResponse, resp *storage.Response) *storage.Response {
+	if len(h.transforms.Rules) == 0 {
+		return resp
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// Prefer the backend the response was actually recorded from
+	backend := h.routeFor(r).Backend
+	if cached.Request != nil && cached.Request.Backend != "" {
+		backend = cached.Request.Backend
+	}
+	ctx := &transform.Context{
+		Request:    r,
+		Recording:  cached,
+		BackendURL: backend,
+		ProxyURL:   scheme + "://" + r.Host,
+		Now:        time.Now(),
+	}
+
+	out, applied, err := h.transforms.Apply(ctx, resp)
+	if err != nil {
+		h.logger.Printf("[TRANSFORM] %v, serving the response as recorded", err)
+		return resp
+	}
+	if len(applied) > 0 {
+		h.logger.Printf("[%s] Applied transforms: %s", tag, strings.Join(applied, ", "))
+	}
+	return out
+}
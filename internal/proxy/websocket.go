@@ -0,0 +1,393 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+	"github.com/yourusername/chameleon/internal/websocket"
+)
+
+// wsCloseTimeout bounds how long a replayed session waits for the client to
+// answer a close frame
+const wsCloseTimeout = 5 * time.Second
+
+// Hijack implements http.Hijacker so the reverse proxy can switch protocols
+// The hijacked connection is tapped to record the WebSocket session
+func (rc *responseCapturer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rc.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The session is saved when it ends, which Wait has to wait for
+	rc.handler.saves.Add(1)
+	rc.handler.trackSession(conn)
+	rc.session = &wsRecorder{start: time.Now(), conn: conn}
+	return &tappedConn{Conn: conn, rec: rc.session}, brw, nil
+}
+
+// wsRecorder collects the messages of a proxied WebSocket session
+type wsRecorder struct {
+	start time.Time
+	conn  net.Conn // The hijacked client connection
+
+	mu       sync.Mutex
+	messages []storage.WebSocketMessage
+	client   wsStream
+	server   wsStream
+}
+
+// wsStream decodes the frames sent in one direction of a session
+type wsStream struct {
+	decoder websocket.Decoder
+	err     error // Set once the stream can't be decoded; recording stops
+}
+
+// observe decodes data sent by from and records the messages it completes
+func (rec *wsRecorder) observe(from string, data []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	stream := &rec.client
+	if from == storage.FromServer {
+		stream = &rec.server
+	}
+	if stream.err != nil {
+		return
+	}
+
+	msgs, err := stream.decoder.Feed(data)
+	stream.err = err
+	offset := time.Since(rec.start)
+	for _, msg := range msgs {
+		// Pings and pongs are keepalives answered by the replay itself
+		if msg.Opcode == websocket.OpPing || msg.Opcode == websocket.OpPong {
+			continue
+		}
+		rec.messages = append(rec.messages, storage.NewWebSocketMessage(from, offset, msg.Opcode.String(), msg.Data))
+	}
+}
+
+// transcript returns the messages recorded so far and the first decoding error, if any
+func (rec *wsRecorder) transcript() ([]storage.WebSocketMessage, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	err := rec.client.err
+	if err == nil {
+		err = rec.server.err
+	}
+	return append([]storage.WebSocketMessage(nil), rec.messages...), err
+}
+
+// tappedConn is a hijacked client connection that records the messages
+// passing through it: reads carry client messages, writes server messages
+type tappedConn struct {
+	net.Conn
+	rec *wsRecorder
+}
+
+func (c *tappedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.rec.observe(storage.FromClient, p[:n])
+	}
+	return n, err
+}
+
+func (c *tappedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.rec.observe(storage.FromServer, p[:n])
+	}
+	return n, err
+}
+
+// trackSession registers the hijacked connection of a WebSocket session so
+// that Stop can close it. A connection hijacked after Stop is closed at once
+func (s *shared) trackSession(conn net.Conn) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	select {
+	case <-s.stopping:
+		conn.Close()
+	default:
+		s.sessions[conn] = struct{}{}
+	}
+}
+
+// untrackSession removes a connection registered by trackSession
+func (s *shared) untrackSession(conn net.Conn) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	delete(s.sessions, conn)
+}
+
+// closeSessions closes the connections of all active WebSocket sessions
+func (s *shared) closeSessions() {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	for conn := range s.sessions {
+		conn.Close()
+	}
+}
+
+// wsStep is a recorded client message and the server messages that followed it
+// The first step of a session has no trigger: it holds what the server sent
+// before the client said anything
+type wsStep struct {
+	trigger *storage.WebSocketMessage
+	replies []storage.WebSocketMessage
+	played  bool
+}
+
+// splitSession groups a transcript into steps, one per client message
+func splitSession(messages []storage.WebSocketMessage) []*wsStep {
+	steps := []*wsStep{{}}
+	for i := range messages {
+		msg := messages[i]
+		if msg.From == storage.FromClient {
+			steps = append(steps, &wsStep{trigger: &msg})
+			continue
+		}
+		last := steps[len(steps)-1]
+		last.replies = append(last.replies, msg)
+	}
+	return steps
+}
+
+// wsReplay plays a recorded session back to a client
+type wsReplay struct {
+	h    *Handler
+	conn net.Conn
+
+	writeMu sync.Mutex
+	closing bool // A close frame has been sent
+
+	// batches carries groups of server messages to send, each with the
+	// offset their timing is relative to
+	batches chan wsBatch
+	done    chan struct{}
+}
+
+// wsBatch is a group of server messages sent relative to a common offset
+type wsBatch struct {
+	base     storage.Duration
+	messages []storage.WebSocketMessage
+}
+
+// replayWebSocket accepts a WebSocket upgrade and plays back the recorded session
+func (h *Handler) replayWebSocket(w http.ResponseWriter, r *http.Request, tag string, resp *storage.Response) {
+	if !websocket.IsUpgrade(r) {
+		h.logger.Printf("[%s] Recording is a WebSocket session but the request is not an upgrade", tag)
+		http.Error(w, "recorded response is a WebSocket session; the request must be a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		h.logger.Printf("[ERROR] Failed to hijack connection for WebSocket replay: %v", err)
+		http.Error(w, fmt.Sprintf("failed to accept WebSocket upgrade: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	h.trackSession(conn)
+	defer h.untrackSession(conn)
+
+	// Answer the handshake with the recorded headers, except for the ones
+	// that belong to the original connection
+	header := make(http.Header, len(resp.Headers))
+	for key, values := range resp.Headers {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Del("Sec-WebSocket-Extensions")
+	header.Del("Content-Length")
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", websocket.AcceptKey(r.Header.Get("Sec-WebSocket-Key")))
+
+	// Write errors are sticky in a bufio.Writer, so Flush reports any of them
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		h.logger.Printf("[ERROR] Failed to write WebSocket handshake: %v", err)
+		return
+	}
+
+	replay := &wsReplay{
+		h:       h,
+		conn:    conn,
+		batches: make(chan wsBatch, 16),
+		done:    make(chan struct{}),
+	}
+	h.logger.Printf("[%s] Replaying WebSocket session: %d messages | Replay: %s", tag, len(resp.WebSocket), h.config.WebSocket.Replay)
+
+	go replay.play(h.config.Stream.Speed)
+	replay.run(brw.Reader, resp.WebSocket)
+	h.logger.Printf("[%s] WebSocket session ended", tag)
+}
+
+// run queues the server messages of a session and reads client messages until
+// the client closes the connection
+// In timed mode every server message is queued at once; in triggered mode only
+// the ones sent before the first client message are, and the rest are queued
+// as matching client messages arrive
+func (s *wsReplay) run(r io.Reader, messages []storage.WebSocketMessage) {
+	defer close(s.done)
+
+	steps := splitSession(messages)
+	if s.h.config.WebSocket.Replay == config.WebSocketReplayTriggered {
+		s.batches <- wsBatch{messages: steps[0].replies}
+	} else {
+		var server []storage.WebSocketMessage
+		for _, msg := range messages {
+			if msg.From == storage.FromServer {
+				server = append(server, msg)
+			}
+		}
+		s.batches <- wsBatch{messages: server}
+		steps = nil
+	}
+
+	var decoder websocket.Decoder
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		msgs, decodeErr := decoder.Feed(buf[:n])
+		for _, msg := range msgs {
+			switch msg.Opcode {
+			case websocket.OpPing:
+				s.write(websocket.OpPong, msg.Data)
+			case websocket.OpPong:
+				// Nothing to answer
+			case websocket.OpClose:
+				// Echo the status code back, as the protocol requires
+				code := msg.Data
+				if len(code) > 2 {
+					code = code[:2]
+				}
+				s.closeWith(code)
+				return
+			default:
+				if step := matchStep(steps, msg); step != nil {
+					s.queue(wsBatch{base: step.trigger.Offset, messages: step.replies})
+				} else if steps != nil {
+					s.h.logger.Printf("[WEBSOCKET] Client message matched no recorded message, ignoring")
+				}
+			}
+		}
+		if decodeErr != nil {
+			s.h.logger.Printf("[WEBSOCKET] Invalid frame from client: %v", decodeErr)
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// matchStep finds the step triggered by a client message: the first unplayed
+// step whose recorded client message is identical, or failing that the last
+// one that is, so repeated requests keep getting answers
+func matchStep(steps []*wsStep, msg websocket.Message) *wsStep {
+	var last *wsStep
+	for _, step := range steps {
+		if step.trigger == nil || step.trigger.Type != msg.Opcode.String() || !bytes.Equal(step.trigger.Bytes(), msg.Data) {
+			continue
+		}
+		if !step.played {
+			step.played = true
+			return step
+		}
+		last = step
+	}
+	return last
+}
+
+// queue hands a batch to the player unless the session has ended
+func (s *wsReplay) queue(batch wsBatch) {
+	select {
+	case s.batches <- batch:
+	case <-s.done:
+	}
+}
+
+// play sends queued batches in order, each message at its recorded offset
+// from the start of the batch divided by speed (0 sends without delay)
+func (s *wsReplay) play(speed float64) {
+	for {
+		var batch wsBatch
+		select {
+		case <-s.done:
+			return
+		case batch = <-s.batches:
+		}
+
+		started := time.Now()
+		for _, msg := range batch.messages {
+			if speed > 0 {
+				delay := time.Duration(float64(msg.Offset-batch.base) / speed)
+				timer := time.NewTimer(time.Until(started.Add(delay)))
+				select {
+				case <-s.done:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+
+			op, err := websocket.ParseOpcode(msg.Type)
+			if err != nil {
+				s.h.logger.Printf("[WEBSOCKET] Skipping recorded message: %v", err)
+				continue
+			}
+			if op == websocket.OpClose {
+				s.closeWith(msg.Bytes())
+				// Give the client a moment to answer before the connection is dropped
+				_ = s.conn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+				return
+			}
+			if !s.write(op, msg.Bytes()) {
+				return
+			}
+		}
+	}
+}
+
+// write sends a message to the client, reporting whether it succeeded
+func (s *wsReplay) write(op websocket.Opcode, data []byte) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	if err := websocket.WriteMessage(s.conn, op, data); err != nil {
+		s.h.logger.Printf("[WEBSOCKET] %v", err)
+		return false
+	}
+	return true
+}
+
+// closeWith sends a close frame with payload, unless one has already been sent
+// The lock is held throughout, so no message can follow the close frame
+func (s *wsReplay) closeWith(payload []byte) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.closing {
+		return
+	}
+	s.closing = true
+	if err := websocket.WriteMessage(s.conn, websocket.OpClose, payload); err != nil {
+		s.h.logger.Printf("[WEBSOCKET] %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/config"
+	"github.com/yourusername/chameleon/internal/storage"
+	"github.com/yourusername/chameleon/internal/websocket"
+)
+
+// newWebSocketBackend accepts WebSocket upgrades, greets the client with a
+// text message and keeps the connection open until the client closes it
+func newWebSocketBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("backend hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			websocket.AcceptKey(r.Header.Get("Sec-WebSocket-Key")))
+		websocket.WriteMessage(brw, websocket.OpText, []byte("hello"))
+		brw.Flush()
+		io.Copy(io.Discard, conn)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+// dialWebSocket upgrades a connection to the server at addr and returns it
+// once the first message has arrived
+func dialWebSocket(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", addr)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("reading the handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want 101", resp.StatusCode)
+	}
+
+	var decoder websocket.Decoder
+	buf := make([]byte, 1024)
+	for {
+		n, err := br.Read(buf)
+		msgs, decodeErr := decoder.Feed(buf[:n])
+		if decodeErr != nil {
+			t.Fatalf("decoding: %v", decodeErr)
+		}
+		if len(msgs) > 0 {
+			return conn
+		}
+		if err != nil {
+			t.Fatalf("reading the first message: %v", err)
+		}
+	}
+}
+
+func TestStopSavesRecordedSession(t *testing.T) {
+	backend := newWebSocketBackend(t)
+	st := storage.NewMemoryStore()
+	h := newTestHandler(t, testConfig(config.ModeRecord, backend.URL), st)
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+
+	conn := dialWebSocket(t, strings.TrimPrefix(proxy.URL, "http://"))
+
+	// The session is still open; Stop ends it and Wait waits for its recording
+	h.Stop()
+	waited := make(chan struct{})
+	go func() {
+		h.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Wait did not return after Stop")
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("client connection still open after Stop")
+	}
+
+	keys, err := st.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("List = %v after Wait, want the session", keys)
+	}
+	cached, err := st.Load(keys[0])
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cached.StatusCode != http.StatusSwitchingProtocols || len(cached.WebSocket) != 1 || cached.WebSocket[0].Text != "hello" {
+		t.Errorf("recorded %d with messages %+v, want 101 with the greeting", cached.StatusCode, cached.WebSocket)
+	}
+}
+
+func TestStopClosesReplayedSession(t *testing.T) {
+	st := storage.NewMemoryStore()
+	cfg := testConfig(config.ModeReplay, "http://localhost:1")
+	h := newTestHandler(t, cfg, st)
+	key, err := h.key(httptest.NewRequest(http.MethodGet, "/ws", nil), nil)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	err = st.Save(key, &storage.CachedResponse{
+		Method: http.MethodGet,
+		Path:   "/ws",
+		Response: storage.Response{
+			StatusCode: http.StatusSwitchingProtocols,
+			WebSocket: []storage.WebSocketMessage{
+				storage.NewWebSocketMessage(storage.FromServer, 0, "text", []byte("hello")),
+				storage.NewWebSocketMessage(storage.FromServer, time.Hour, "text", []byte("much later")),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+
+	conn := dialWebSocket(t, strings.TrimPrefix(proxy.URL, "http://"))
+	h.Stop()
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		t.Errorf("client connection not closed by Stop: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Pattern matches requests by method and path
+// The syntax is an optional method followed by a path template:
+//
+//	/api/users            exact path, any method
+//	GET /api/users/{id}   {name} or * matches exactly one path segment
+//	POST /api/files/*     a trailing * matches any remaining segments
+//	*                     every request
+type Pattern struct {
+	raw      string
+	method   string
+	path     string
+	segments []string
+	prefix   bool
+}
+
+// Parse compiles a route pattern
+func Parse(expr string) (*Pattern, error) {
+	raw := strings.TrimSpace(expr)
+	if raw == "" {
+		return nil, fmt.Errorf("invalid route pattern: empty expression")
+	}
+
+	p := &Pattern{raw: raw}
+	path := raw
+	if fields := strings.Fields(raw); len(fields) == 2 {
+		p.method = strings.ToUpper(fields[0])
+		path = fields[1]
+	} else if len(fields) > 2 {
+		return nil, fmt.Errorf("invalid route pattern %q: expected \"[METHOD] /path\"", raw)
+	}
+	if p.method == "*" {
+		p.method = ""
+	}
+
+	if path == "*" {
+		p.path = "/*"
+		p.prefix = true
+		return p, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid route pattern %q: path must start with /", raw)
+	}
+	p.path = path
+
+	p.segments = splitPath(path)
+	if n := len(p.segments); n > 0 && p.segments[n-1] == "*" {
+		p.segments = p.segments[:n-1]
+		p.prefix = true
+	}
+	for _, seg := range p.segments {
+		if strings.HasPrefix(seg, "{") != strings.HasSuffix(seg, "}") {
+			return nil, fmt.Errorf("invalid route pattern %q: malformed parameter %q", raw, seg)
+		}
+	}
+
+	return p, nil
+}
+
+// MustParse is like Parse but panics on invalid patterns
+func MustParse(expr string) *Pattern {
+	p, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the expression the pattern was compiled from
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Method returns the method the pattern is restricted to, or "" for any method
+func (p *Pattern) Method() string {
+	return p.method
+}
+
+// Template returns the path part of the pattern, such as /api/users/{id}
+func (p *Pattern) Template() string {
+	return p.path
+}
+
+// Match reports whether a request with the given method and path matches the pattern
+func (p *Pattern) Match(method, path string) bool {
+	if p.method != "" && p.method != strings.ToUpper(method) {
+		return false
+	}
+
+	segments := splitPath(path)
+	if len(segments) < len(p.segments) || (!p.prefix && len(segments) != len(p.segments)) {
+		return false
+	}
+
+	for i, seg := range p.segments {
+		if seg == "*" || strings.HasPrefix(seg, "{") {
+			continue
+		}
+		if seg != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchRequest reports whether r matches the pattern
+func (p *Pattern) MatchRequest(r *http.Request) bool {
+	return p.Match(r.Method, r.URL.Path)
+}
+
+// CutPathPrefix reports whether path starts with prefix on whole segments, so
+// /api/v1 is a prefix of /api/v1 and /api/v1/users but not of /api/v10
+// A trailing slash on prefix makes no difference. rest is what follows prefix
+// in path: empty, or starting with a slash
+func CutPathPrefix(path, prefix string) (rest string, ok bool) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if path == prefix {
+		return "", true
+	}
+	if rest, ok := strings.CutPrefix(path, prefix); ok && strings.HasPrefix(rest, "/") {
+		return rest, true
+	}
+	return "", false
+}
+
+// splitPath splits a URL path into its non-empty segments
+func splitPath(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,73 @@
+package route
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, method, path string
+		want                  bool
+	}{
+		{"/api/users", "GET", "/api/users", true},
+		{"/api/users", "POST", "/api/users/", true},
+		{"/api/users", "GET", "/api/users/1", false},
+		{"GET /api/users/{id}", "GET", "/api/users/42", true},
+		{"GET /api/users/{id}", "get", "/api/users/42", true},
+		{"GET /api/users/{id}", "DELETE", "/api/users/42", false},
+		{"GET /api/users/{id}", "GET", "/api/users", false},
+		{"GET /api/users/{id}", "GET", "/api/users/42/posts", false},
+		{"/api/*/posts", "GET", "/api/7/posts", true},
+		{"POST /api/files/*", "POST", "/api/files/a/b/c", true},
+		{"POST /api/files/*", "POST", "/api/files", true},
+		{"POST /api/files/*", "POST", "/api/filesystem", false},
+		{"* /api/users", "PUT", "/api/users", true},
+		{"*", "PATCH", "/anything/at/all", true},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.pattern).Match(tt.method, tt.path); got != tt.want {
+			t.Errorf("%q matches %s %s = %v, want %v", tt.pattern, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	p := MustParse("  get /api/users/{id}  ")
+	if p.Method() != "GET" || p.Template() != "/api/users/{id}" || p.String() != "get /api/users/{id}" {
+		t.Errorf("Parse = method %q, template %q, string %q", p.Method(), p.Template(), p.String())
+	}
+
+	for _, expr := range []string{
+		"",
+		"api/users",
+		"GET /api/users extra",
+		"/api/{id",
+		"/api/id}",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCutPathPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		rest         string
+		ok           bool
+	}{
+		{"/api/v1", "/api/v1", "", true},
+		{"/api/v1/users", "/api/v1", "/users", true},
+		{"/api/v1/users", "/api/v1/", "/users", true},
+		{"/api/v1", "/api/v1/", "", true},
+		{"/api/v10", "/api/v1", "", false},
+		{"/api/v10/users", "/api/v1/", "", false},
+		{"/api", "/api/v1", "", false},
+		{"/api/v1", "/", "/api/v1", true},
+		{"/api/v1", "", "/api/v1", true},
+	}
+	for _, tt := range tests {
+		rest, ok := CutPathPrefix(tt.path, tt.prefix)
+		if rest != tt.rest || ok != tt.ok {
+			t.Errorf("CutPathPrefix(%q, %q) = %q, %v, want %q, %v", tt.path, tt.prefix, rest, ok, tt.rest, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// archiveVersion is the version of the archive file format
+const archiveVersion = 1
+
+// archiveFile is the layout of an archive file
+type archiveFile struct {
+	Version    int                        `json:"version"`
+	Recordings map[string]json.RawMessage `json:"recordings"`
+}
+
+// ArchiveStore keeps every cached response in a single JSON file, which is
+// easier to commit and share than a directory of files
+// The archive is held in memory and rewritten on every change, so it suits
+// fixture sets more than large recording sessions
+type ArchiveStore struct {
+	*MemoryStore
+	path string
+
+	// writeMu serializes changes so the file always matches memory
+	writeMu sync.Mutex
+}
+
+// OpenArchive opens the archive file at path, which is created on the first
+// save if it doesn't exist
+func OpenArchive(path string) (*ArchiveStore, error) {
+	a := &ArchiveStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	var file archiveFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse archive %s: %w", path, err)
+	}
+	if file.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d in %s (expected %d)", file.Version, path, archiveVersion)
+	}
+	for key, recording := range file.Recordings {
+		a.entries[key] = recording
+	}
+	return a, nil
+}
+
+// Save stores response under key and writes the archive
+func (a *ArchiveStore) Save(key string, response *CachedResponse) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	if err := a.MemoryStore.Save(key, response); err != nil {
+		return err
+	}
+	return a.write()
+}
+
+// Delete removes the cached response stored under key and writes the archive
+func (a *ArchiveStore) Delete(key string) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	if err := a.MemoryStore.Delete(key); err != nil {
+		return err
+	}
+	return a.write()
+}
+
+// write replaces the archive file with the recordings in memory
+// The file is replaced in one step so readers never see it half-written
+func (a *ArchiveStore) write() error {
+	file := archiveFile{Version: archiveVersion, Recordings: make(map[string]json.RawMessage)}
+	a.mu.RLock()
+	for key, data := range a.entries {
+		file.Recordings[key] = data
+	}
+	a.mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive: %w", err)
+	}
+
+	dir := filepath.Dir(a.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(a.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), a.path); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
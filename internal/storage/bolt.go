@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of a BoltStore database
+var (
+	bucketRecordings  = []byte("recordings")   // Key -> recording
+	bucketSummaries   = []byte("summaries")    // Key -> Summary
+	bucketIndexMethod = []byte("index.method") // METHOD \x00 key
+	bucketIndexPath   = []byte("index.path")   // path \x00 key
+	bucketIndexStatus = []byte("index.status") // 3-digit status \x00 key
+)
+
+// iterateBatch is how many recordings Iterate reads per transaction
+// fn runs outside transactions, so it may modify the store
+const iterateBatch = 256
+
+// BoltStore keeps recordings in an embedded bbolt database, indexed by
+// method, path and status so that large recording sets stay fast to list and
+// search
+// The database file is locked while open: a writer excludes every other
+// process, readers only exclude writers
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens the database file at path, creating it if it doesn't exist
+func OpenBolt(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	b, err := openBolt(path, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketRecordings, bucketSummaries, bucketIndexMethod, bucketIndexPath, bucketIndexStatus} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.db.Close()
+		return nil, fmt.Errorf("failed to initialize database %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// OpenBoltReadOnly opens the existing database file at path for reading
+// It takes a shared lock, so any number of readers can use the file at once,
+// but not while a writer, such as a running proxy, holds it
+func OpenBoltReadOnly(path string) (*BoltStore, error) {
+	b, err := openBolt(path, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketRecordings) == nil {
+			return fmt.Errorf("not a recordings database")
+		}
+		return nil
+	})
+	if err != nil {
+		b.db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// openBolt opens the database file at path with opts
+func openBolt(path string, opts *bolt.Options) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, opts)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open database %s: it is in use by another process, such as a running proxy", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close closes the database and releases its lock
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// Exists checks if a cached response is stored under key
+func (b *BoltStore) Exists(key string) bool {
+	found := false
+	b.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(bucketRecordings).Get([]byte(key)) != nil
+		return nil
+	})
+	return found
+}
+
+// Load returns the cached response stored under key
+func (b *BoltStore) Load(key string) (*CachedResponse, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		// Values are only valid during the transaction
+		data = append(data, tx.Bucket(bucketRecordings).Get([]byte(key))...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached response: %w", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("failed to read cached response: %s not found", key)
+	}
+	return decode(data)
+}
+
+// Save stores response under key and indexes it
+func (b *BoltStore) Save(key string, response *CachedResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+	summary := Summarize(key, response)
+	summaryData, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		if err := unindex(tx, key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketRecordings).Put([]byte(key), data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketSummaries).Put([]byte(key), summaryData); err != nil {
+			return err
+		}
+		for bucket, value := range indexValues(summary) {
+			if err := tx.Bucket([]byte(bucket)).Put(indexKey(value, key), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the cached response stored under key and its index entries
+func (b *BoltStore) Delete(key string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		recordings := tx.Bucket(bucketRecordings)
+		if recordings.Get([]byte(key)) == nil {
+			return fmt.Errorf("%s not found", key)
+		}
+		if err := unindex(tx, key); err != nil {
+			return err
+		}
+		return recordings.Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete cached response: %w", err)
+	}
+	return nil
+}
+
+// List returns the keys of all cached responses, sorted
+func (b *BoltStore) List() ([]string, error) {
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRecordings).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+	return keys, nil
+}
+
+// Iterate calls fn with every cached response, in key order
+func (b *BoltStore) Iterate(fn func(key string, cached *CachedResponse, err error) error) error {
+	type entry struct {
+		key  string
+		data []byte
+	}
+
+	var after []byte
+	for {
+		// Read a batch, then call fn outside the transaction
+		var batch []entry
+		err := b.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(bucketRecordings).Cursor()
+			k, v := c.First()
+			if after != nil {
+				k, v = c.Seek(after)
+				if bytes.Equal(k, after) {
+					k, v = c.Next()
+				}
+			}
+			for ; k != nil && len(batch) < iterateBatch; k, v = c.Next() {
+				batch = append(batch, entry{key: string(k), data: append([]byte(nil), v...)})
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read database: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, e := range batch {
+			cached, err := decode(e.data)
+			if err := fn(e.key, cached, err); err != nil {
+				return err
+			}
+		}
+		after = []byte(batch[len(batch)-1].key)
+	}
+}
+
+// Search returns the summaries of the recordings matching q, sorted by key
+// It scans the most selective index q allows
+func (b *BoltStore) Search(q Query) ([]Summary, error) {
+	var results []Summary
+	err := b.db.View(func(tx *bolt.Tx) error {
+		summaries := tx.Bucket(bucketSummaries)
+		consider := func(key []byte) error {
+			data := summaries.Get(key)
+			if data == nil {
+				return nil
+			}
+			var s Summary
+			if err := json.Unmarshal(data, &s); err != nil {
+				return fmt.Errorf("invalid summary of %s: %w", key, err)
+			}
+			if q.Matches(s) {
+				results = append(results, s)
+			}
+			return nil
+		}
+
+		bucket, from, to := indexRange(q)
+		if bucket == nil {
+			return summaries.ForEach(func(k, _ []byte) error {
+				return consider(k)
+			})
+		}
+		c := tx.Bucket(bucket).Cursor()
+		for k, _ := c.Seek(from); k != nil && bytes.Compare(k, to) < 0; k, _ = c.Next() {
+			i := bytes.IndexByte(k, 0)
+			if i < 0 {
+				continue
+			}
+			if err := consider(k[i+1:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search database: %w", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+	return results, nil
+}
+
+// indexRange picks the index to scan for q and the range of its keys,
+// [from, to). A nil bucket means no index helps and every summary is checked
+func indexRange(q Query) (bucket, from, to []byte) {
+	switch {
+	case strings.TrimSuffix(q.PathPrefix, "/") != "":
+		// The range holds paths within the prefix and some that merely start
+		// with it, such as /api/v10 for /api/v1; Matches drops those
+		prefix := []byte(strings.TrimSuffix(q.PathPrefix, "/"))
+		return bucketIndexPath, prefix, prefixEnd(prefix)
+	case q.MinStatus != 0 || q.MaxStatus != 0:
+		max := q.MaxStatus
+		if max == 0 || max > 999 {
+			max = 999
+		}
+		return bucketIndexStatus, []byte(statusValue(q.MinStatus)), []byte(statusValue(max + 1))
+	case q.Method != "":
+		prefix := indexKey(strings.ToUpper(q.Method), "")
+		return bucketIndexMethod, prefix, prefixEnd(prefix)
+	}
+	return nil, nil, nil
+}
+
+// prefixEnd returns the first key after every key starting with prefix
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Only 0xff bytes: no key sorts after all of them
+	return bytes.Repeat([]byte{0xff}, len(prefix)+1)
+}
+
+// indexValues returns the value a summary is indexed under in each index bucket
+func indexValues(s Summary) map[string]string {
+	return map[string]string{
+		string(bucketIndexMethod): strings.ToUpper(s.Method),
+		string(bucketIndexPath):   s.Path,
+		string(bucketIndexStatus): statusValue(s.StatusCode),
+	}
+}
+
+// statusValue formats a status code so that codes sort numerically
+func statusValue(code int) string {
+	return fmt.Sprintf("%03d", code)
+}
+
+// indexKey returns the index entry of value for key
+func indexKey(value, key string) []byte {
+	return []byte(value + "\x00" + key)
+}
+
+// unindex removes the summary and index entries of key, if it has any
+func unindex(tx *bolt.Tx, key string) error {
+	summaries := tx.Bucket(bucketSummaries)
+	data := summaries.Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+	var s Summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid summary of %s: %w", key, err)
+	}
+	for bucket, value := range indexValues(s) {
+		if err := tx.Bucket([]byte(bucket)).Delete(indexKey(value, key)); err != nil {
+			return err
+		}
+	}
+	return summaries.Delete([]byte(key))
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps cached responses in memory
+// Responses are stored encoded, so callers never share them with the store
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string][]byte)}
+}
+
+// Exists checks if a cached response is stored under key
+func (m *MemoryStore) Exists(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.entries[key]
+	return ok
+}
+
+// Load returns the cached response stored under key
+func (m *MemoryStore) Load(key string) (*CachedResponse, error) {
+	m.mu.RLock()
+	data, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to read cached response: %s not found", key)
+	}
+	return decode(data)
+}
+
+// Save stores response under key
+func (m *MemoryStore) Save(key string, response *CachedResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = data
+	return nil
+}
+
+// Delete removes the cached response stored under key
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[key]; !ok {
+		return fmt.Errorf("failed to delete cached response: %s not found", key)
+	}
+	delete(m.entries, key)
+	return nil
+}
+
+// List returns the keys of all cached responses, sorted
+func (m *MemoryStore) List() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Iterate calls fn with every cached response, in key order
+func (m *MemoryStore) Iterate(fn func(key string, cached *CachedResponse, err error) error) error {
+	keys, _ := m.List()
+	for _, key := range keys {
+		m.mu.RLock()
+		data, ok := m.entries[key]
+		m.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		cached, err := decode(data)
+		if err := fn(key, cached, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode unmarshals a stored cached response
+func decode(data []byte) (*CachedResponse, error) {
+	var cached CachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached response: %w", err)
+	}
+	return &cached, nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/route"
+)
+
+// Summary describes a recording without its bodies, for listing and search
+type Summary struct {
+	Key        string    `json:"key"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	URL        string    `json:"url,omitempty"` // Request URI including the query, if the request was recorded
+	StatusCode int       `json:"status_code"`
+	Responses  int       `json:"responses"`             // Number of responses, more than 1 for sequences
+	RecordedAt time.Time `json:"recorded_at,omitempty"` // Zero for recordings made before requests were stored
+}
+
+// Summarize describes the recording stored under key
+func Summarize(key string, cached *CachedResponse) Summary {
+	s := Summary{
+		Key:        key,
+		Method:     cached.Method,
+		Path:       cached.Path,
+		StatusCode: cached.StatusCode,
+		Responses:  1 + len(cached.Sequence),
+	}
+	if cached.Request != nil {
+		s.URL = cached.Request.URL
+		s.RecordedAt = cached.Request.Timestamp
+	}
+	return s
+}
+
+// Query selects recordings by the fields stores index; zero fields match
+// every recording
+type Query struct {
+	Method     string
+	PathPrefix string // Matched on whole segments, e.g. /api/v1 selects /api/v1/users but not /api/v10
+	MinStatus  int    // Inclusive bounds of the status code
+	MaxStatus  int
+}
+
+// Matches reports whether the recording described by s is selected by q
+func (q Query) Matches(s Summary) bool {
+	if q.Method != "" && !strings.EqualFold(q.Method, s.Method) {
+		return false
+	}
+	if _, ok := route.CutPathPrefix(s.Path, q.PathPrefix); !ok {
+		return false
+	}
+	if q.MinStatus != 0 && s.StatusCode < q.MinStatus {
+		return false
+	}
+	if q.MaxStatus != 0 && s.StatusCode > q.MaxStatus {
+		return false
+	}
+	return true
+}
+
+// Searcher is implemented by stores that index recordings, so they can be
+// searched without loading every one
+type Searcher interface {
+	// Search returns the summaries of the recordings matching q, sorted by key
+	Search(q Query) ([]Summary, error)
+}
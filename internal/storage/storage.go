@@ -1,11 +1,19 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // ResponseBody is a custom type that can store any content (JSON, HTML, text, etc.)
@@ -53,41 +61,313 @@ func (rb *ResponseBody) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// CachedResponse represents a cached HTTP response
-type CachedResponse struct {
-	Method     string              `json:"method"`
-	Path       string              `json:"path"`
+// Response is a single recorded HTTP response
+type Response struct {
 	StatusCode int                 `json:"status_code"`
 	Headers    map[string][]string `json:"headers"`
 	Body       ResponseBody        `json:"body"`
+	// Latency is how long the backend took to start answering
+	Latency Duration `json:"latency,omitempty"`
+	// Chunks keeps the flush boundaries of streamed responses (Server-Sent
+	// Events, long chunked responses); Body still holds the complete payload
+	Chunks []Chunk `json:"chunks,omitempty"`
+	// WebSocket is the message transcript of a session that switched protocols
+	WebSocket []WebSocketMessage `json:"websocket,omitempty"`
+}
+
+// Chunk is a piece of a streamed response body
+// Text chunks are stored as plain strings for readability, anything that
+// isn't valid UTF-8 as base64-encoded Data
+type Chunk struct {
+	Offset Duration `json:"offset"` // Time since the response headers were sent
+	Text   string   `json:"text,omitempty"`
+	Data   []byte   `json:"data,omitempty"`
+}
+
+// NewChunk creates a chunk holding data, sent offset after the response headers
+func NewChunk(offset time.Duration, data []byte) Chunk {
+	if utf8.Valid(data) {
+		return Chunk{Offset: Duration(offset), Text: string(data)}
+	}
+	return Chunk{Offset: Duration(offset), Data: append([]byte(nil), data...)}
+}
+
+// Bytes returns the chunk payload
+func (c Chunk) Bytes() []byte {
+	if c.Text != "" {
+		return []byte(c.Text)
+	}
+	return c.Data
+}
+
+// WebSocket message senders
+const (
+	FromClient = "client"
+	FromServer = "server"
+)
+
+// WebSocketMessage is a message sent over a recorded WebSocket session
+// Like chunks, text messages are stored as plain strings and anything else as
+// base64-encoded Data
+type WebSocketMessage struct {
+	From   string   `json:"from"`   // client or server
+	Offset Duration `json:"offset"` // Time since the connection was upgraded
+	Type   string   `json:"type"`   // text, binary, or close
+	Text   string   `json:"text,omitempty"`
+	Data   []byte   `json:"data,omitempty"`
+}
+
+// NewWebSocketMessage creates a message of type msgType sent by from, offset after the upgrade
+func NewWebSocketMessage(from string, offset time.Duration, msgType string, data []byte) WebSocketMessage {
+	msg := WebSocketMessage{From: from, Offset: Duration(offset), Type: msgType}
+	if msgType == "text" && utf8.Valid(data) {
+		msg.Text = string(data)
+	} else {
+		msg.Data = append([]byte(nil), data...)
+	}
+	return msg
+}
+
+// Bytes returns the message payload
+func (m WebSocketMessage) Bytes() []byte {
+	if m.Text != "" {
+		return []byte(m.Text)
+	}
+	return m.Data
+}
+
+// CachedResponse represents a cached HTTP response
+// The first response is stored inline; responses recorded later for the same
+// key (e.g. successive polls of a job status) are kept in Sequence
+type CachedResponse struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Response
+	Request  *CachedRequest `json:"request,omitempty"`
+	Sequence []Response     `json:"sequence,omitempty"`
+}
+
+// Responses returns every recorded response in the order it was recorded
+func (c *CachedResponse) Responses() []Response {
+	return append([]Response{c.Response}, c.Sequence...)
+}
+
+// Append adds a response to the end of the recorded sequence
+func (c *CachedResponse) Append(resp Response) {
+	c.Sequence = append(c.Sequence, resp)
+}
+
+// CachedRequest describes the request a response was recorded for
+// It keeps everything needed to inspect the recording, diff it against other
+// requests, or compute its key again under a different matching strategy
+type CachedRequest struct {
+	URL        string              `json:"url"`               // Request URI including the query string
+	Host       string              `json:"host,omitempty"`    // Host the client addressed the request to
+	Backend    string              `json:"backend,omitempty"` // Backend the request was forwarded to
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       RequestBody         `json:"body,omitempty"`
+	RemoteAddr string              `json:"remote_addr,omitempty"`
+	Timestamp  time.Time           `json:"timestamp"`
+	Duration   Duration            `json:"duration"` // Round trip through the backend
+}
+
+// RequestBody is the body of a recorded request, stored byte for byte as
+// {"text": "..."} if it is UTF-8 and {"base64": "..."} otherwise, so the
+// request hashes the same when its key is computed again
+type RequestBody []byte
+
+// requestBodyJSON is the stored form of a RequestBody
+type requestBodyJSON struct {
+	Text   *string `json:"text,omitempty"`
+	Base64 *string `json:"base64,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler for RequestBody
+func (rb RequestBody) MarshalJSON() ([]byte, error) {
+	if len(rb) == 0 {
+		return []byte("null"), nil
+	}
+	var stored requestBodyJSON
+	if utf8.Valid(rb) {
+		text := string(rb)
+		stored.Text = &text
+	} else {
+		encoded := base64.StdEncoding.EncodeToString(rb)
+		stored.Base64 = &encoded
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for RequestBody
+func (rb *RequestBody) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*rb = nil
+		return nil
+	}
+	var stored requestBodyJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&stored); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	switch {
+	case stored.Text != nil && stored.Base64 == nil:
+		*rb = RequestBody(*stored.Text)
+	case stored.Base64 != nil && stored.Text == nil:
+		decoded, err := base64.StdEncoding.DecodeString(*stored.Base64)
+		if err != nil {
+			return fmt.Errorf("invalid request body: %w", err)
+		}
+		*rb = RequestBody(decoded)
+	default:
+		return fmt.Errorf("invalid request body: must have either text or base64")
+	}
+	return nil
+}
+
+// RawQuery returns the encoded query string of the recorded request
+func (c *CachedRequest) RawQuery() string {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return ""
+	}
+	return u.RawQuery
+}
+
+// HTTPRequest rebuilds the originating request of a recording along with its body
+// Returns an error if the recording was made before requests were stored
+func (c *CachedResponse) HTTPRequest() (*http.Request, []byte, error) {
+	if c.Request == nil {
+		return nil, nil, fmt.Errorf("recording has no request data")
+	}
+
+	body := []byte(c.Request.Body)
+	req, err := http.NewRequest(c.Method, c.Request.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to rebuild request: %w", err)
+	}
+	for key, values := range c.Request.Headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.Host = c.Request.Host
+	req.RemoteAddr = c.Request.RemoteAddr
+
+	return req, body, nil
 }
 
-// Storage handles saving and loading cached responses
-type Storage struct {
+// Duration is a time.Duration stored as a human-readable string such as "132.5ms"
+type Duration time.Duration
+
+// String formats the duration like time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON implements json.Marshaler for Duration
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler for Duration
+// Accepts duration strings as well as plain numbers of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		var ns int64
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("invalid duration: %s", data)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Key returns the storage key of a recording made under namespace
+// Namespaced recordings are stored in a subdirectory named after the namespace
+func Key(namespace, hash string) string {
+	if namespace == "" {
+		return hash
+	}
+	return namespace + "/" + hash
+}
+
+// SplitKey splits a storage key into its namespace and hash
+func SplitKey(key string) (namespace, hash string) {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// cassettesNamespace is the namespace named cassettes are stored under
+const cassettesNamespace = "cassettes"
+
+// cassettePattern restricts cassette names, which become storage directories
+var cassettePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidCassette reports whether name can name a cassette
+func ValidCassette(name string) bool {
+	return cassettePattern.MatchString(name)
+}
+
+// CassetteKey returns the storage key of key within a named cassette, an
+// isolated set of recordings. The empty cassette is the default set, which
+// stores key as it is
+func CassetteKey(cassette, key string) string {
+	if cassette == "" {
+		return key
+	}
+	return cassettesNamespace + "/" + cassette + "/" + key
+}
+
+// SplitCassette splits a storage key into its cassette and the key within it
+func SplitCassette(key string) (cassette, rest string) {
+	after, ok := strings.CutPrefix(key, cassettesNamespace+"/")
+	if !ok {
+		return "", key
+	}
+	// A route named like the cassettes namespace stores bare hashes in it
+	i := strings.Index(after, "/")
+	if i < 0 {
+		return "", key
+	}
+	return after[:i], after[i+1:]
+}
+
+// FileStore stores each cached response as a JSON file under a directory
+type FileStore struct {
 	basePath string
 }
 
-// New creates a new Storage instance
-func New(basePath string) (*Storage, error) {
+// NewFileStore creates a FileStore keeping its files under basePath
+func NewFileStore(basePath string) (*FileStore, error) {
 	// Create the storage directory if it doesn't exist
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	return &Storage{
+	return &FileStore{
 		basePath: basePath,
 	}, nil
 }
 
 // Exists checks if a cached response exists for the given hash
-func (s *Storage) Exists(hash string) bool {
+func (s *FileStore) Exists(hash string) bool {
 	filename := s.getFilename(hash)
 	_, err := os.Stat(filename)
 	return err == nil
 }
 
 // Load loads a cached response by hash
-func (s *Storage) Load(hash string) (*CachedResponse, error) {
+func (s *FileStore) Load(hash string) (*CachedResponse, error) {
 	filename := s.getFilename(hash)
 
 	data, err := os.ReadFile(filename)
@@ -104,7 +384,7 @@ func (s *Storage) Load(hash string) (*CachedResponse, error) {
 }
 
 // Save saves a cached response using the hash as filename
-func (s *Storage) Save(hash string, response *CachedResponse) error {
+func (s *FileStore) Save(hash string, response *CachedResponse) error {
 	filename := s.getFilename(hash)
 
 	// Pretty print JSON with 2-space indentation
@@ -113,6 +393,9 @@ func (s *Storage) Save(hash string, response *CachedResponse) error {
 		return fmt.Errorf("failed to marshal cached response: %w", err)
 	}
 
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
 	if err := os.WriteFile(filename, data, 0644); err != nil {
 		return fmt.Errorf("failed to write cached response: %w", err)
 	}
@@ -120,7 +403,65 @@ func (s *Storage) Save(hash string, response *CachedResponse) error {
 	return nil
 }
 
-// getFilename returns the full file path for a given hash
-func (s *Storage) getFilename(hash string) string {
-	return filepath.Join(s.basePath, fmt.Sprintf("%s.json", hash))
+// Delete removes the cached response stored under key
+func (s *FileStore) Delete(key string) error {
+	if err := os.Remove(s.getFilename(key)); err != nil {
+		return fmt.Errorf("failed to delete cached response: %w", err)
+	}
+	return nil
+}
+
+// List returns the keys of all cached responses, sorted
+// Recordings in namespaces are listed as "namespace/hash"
+func (s *FileStore) List() ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.basePath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.basePath, path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, strings.TrimSuffix(filepath.ToSlash(rel), ".json"))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Iterate calls fn with every cached response, in key order
+func (s *FileStore) Iterate(fn func(key string, cached *CachedResponse, err error) error) error {
+	keys, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		cached, err := s.Load(key)
+		if err := fn(key, cached, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ModTime returns when the cached response stored under key was last written
+func (s *FileStore) ModTime(key string) (time.Time, error) {
+	info, err := os.Stat(s.getFilename(key))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// getFilename returns the full file path for a given key
+func (s *FileStore) getFilename(key string) string {
+	return filepath.Join(s.basePath, filepath.FromSlash(key)+".json")
 }
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestBodyJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   RequestBody
+		stored string
+	}{
+		{"empty", nil, `null`},
+		{"JSON kept as sent", RequestBody(`{"b": 1,  "a":2}`), `{"text":"{\"b\": 1,  \"a\":2}"}`},
+		{"text", RequestBody("hello, chameleon"), `{"text":"hello, chameleon"}`},
+		{"binary", RequestBody{0xff, 0x00, 0x7f}, `{"base64":"/wB/"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.body)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != tt.stored {
+				t.Errorf("Marshal = %s, want %s", data, tt.stored)
+			}
+			var got RequestBody
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("round trip = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestRequestBodyInvalid(t *testing.T) {
+	for _, stored := range []string{
+		`{"query": "chameleon"}`,
+		`"text"`,
+		`{}`,
+		`{"text": "a", "base64": "YQ=="}`,
+		`{"base64": "%%%"}`,
+	} {
+		var body RequestBody
+		if err := json.Unmarshal([]byte(stored), &body); err == nil {
+			t.Errorf("Unmarshal(%s) = %q, want an error", stored, body)
+		}
+	}
+}
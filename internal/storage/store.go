@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+)
+
+// Store holds cached responses by key
+// Keys are hashes, optionally prefixed with a namespace as built by Key
+type Store interface {
+	// Exists reports whether a cached response is stored under key
+	Exists(key string) bool
+	// Load returns the cached response stored under key
+	Load(key string) (*CachedResponse, error)
+	// Save stores response under key, replacing any previous one
+	Save(key string, response *CachedResponse) error
+	// Delete removes the cached response stored under key
+	Delete(key string) error
+	// List returns the keys of all cached responses, sorted
+	List() ([]string, error)
+	// Iterate calls fn with every cached response, in key order
+	// Responses that can't be read are passed with a nil response and the error
+	// Iteration stops at the first error fn returns, which Iterate returns
+	// Recordings fn saves or deletes may or may not be visited
+	Iterate(fn func(key string, cached *CachedResponse, err error) error) error
+}
+
+// Storage backends, selected with STORAGE_BACKEND
+const (
+	BackendFile    = "file"    // One JSON file per recording under STORAGE_PATH (default)
+	BackendMemory  = "memory"  // Kept in memory and lost on exit, for tests
+	BackendArchive = "archive" // Every recording in the single JSON file STORAGE_PATH
+	BackendBolt    = "bolt"    // Indexed bbolt database file STORAGE_PATH, for large recording sets
+)
+
+// Open opens the store of the named backend at path
+// An empty backend selects the file backend
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendFile:
+		return NewFileStore(path)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendArchive:
+		return OpenArchive(path)
+	case BackendBolt:
+		return OpenBolt(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// OpenReadOnly opens the store of the named backend at path for commands that
+// only read recordings. A bolt database is opened read-only, so that several
+// such commands can use it at once; other backends are opened as by Open
+func OpenReadOnly(backend, path string) (Store, error) {
+	if backend == BackendBolt {
+		return OpenBoltReadOnly(path)
+	}
+	return Open(backend, path)
+}
+
+// Close releases the resources of st, such as the lock of a database file
+// Stores that hold none are left alone
+func Close(st Store) error {
+	if c, ok := st.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// backends opens an empty store of every backend in a temporary directory
+// reopen is set for backends that keep their recordings across Open calls
+var backends = []struct {
+	name   string
+	open   func(t *testing.T, dir string) Store
+	reopen bool
+}{
+	{"file", func(t *testing.T, dir string) Store { return mustOpen(t, BackendFile, dir) }, true},
+	{"memory", func(t *testing.T, dir string) Store { return mustOpen(t, BackendMemory, "") }, false},
+	{"archive", func(t *testing.T, dir string) Store {
+		return mustOpen(t, BackendArchive, filepath.Join(dir, "recordings.json"))
+	}, true},
+	{"bolt", func(t *testing.T, dir string) Store {
+		return mustOpen(t, BackendBolt, filepath.Join(dir, "recordings.db"))
+	}, true},
+}
+
+func mustOpen(t *testing.T, backend, path string) Store {
+	t.Helper()
+	st, err := Open(backend, path)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", backend, err)
+	}
+	t.Cleanup(func() { Close(st) })
+	return st
+}
+
+// recording returns a recording of method path answered with status
+func recording(method, path string, status int) *CachedResponse {
+	return &CachedResponse{
+		Method: method,
+		Path:   path,
+		Response: Response{
+			StatusCode: status,
+			Headers:    map[string][]string{"Content-Type": {"text/plain"}},
+			Body:       ResponseBody("recorded for " + path),
+		},
+		Request: &CachedRequest{
+			URL:       path + "?page=1",
+			Headers:   map[string][]string{"Accept": {"*/*"}},
+			Body:      RequestBody(`{"b": 1,  "a":2}`),
+			Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+// Keys in the order List returns them: plain, cassette, and route namespaced
+var sortedKeys = []string{
+	"0a1b2c3d4e5f6a7b",
+	"auth/9f8e7d6c5b4a3f2e",
+	"auth/a0b1c2d3e4f5a6b7",
+	"cassettes/checkout/1234567890abcdef",
+	"cassettes/checkout/auth/fedcba0987654321",
+	"f0e1d2c3b4a59687",
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	for _, tt := range backends {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.open(t, t.TempDir())
+			key := "auth/0a1b2c3d4e5f6a7b"
+			want := recording("POST", "/api/login", 201)
+
+			if st.Exists(key) {
+				t.Fatalf("Exists(%s) before Save = true", key)
+			}
+			if err := st.Save(key, want); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if !st.Exists(key) {
+				t.Fatalf("Exists(%s) after Save = false", key)
+			}
+			got, err := st.Load(key)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			assertRecording(t, got, want)
+		})
+	}
+}
+
+func TestStoreSaveReplaces(t *testing.T) {
+	for _, tt := range backends {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.open(t, t.TempDir())
+			key := sortedKeys[0]
+			if err := st.Save(key, recording("GET", "/old", 200)); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			want := recording("GET", "/new", 404)
+			if err := st.Save(key, want); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			got, err := st.Load(key)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			assertRecording(t, got, want)
+			keys, err := st.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(keys) != 1 {
+				t.Errorf("List = %v, want one key", keys)
+			}
+		})
+	}
+}
+
+func TestStoreDelete(t *testing.T) {
+	for _, tt := range backends {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.open(t, t.TempDir())
+			for _, key := range sortedKeys[:2] {
+				if err := st.Save(key, recording("GET", "/"+key, 200)); err != nil {
+					t.Fatalf("Save: %v", err)
+				}
+			}
+
+			if err := st.Delete(sortedKeys[1]); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if st.Exists(sortedKeys[1]) {
+				t.Errorf("Exists after Delete = true")
+			}
+			if _, err := st.Load(sortedKeys[1]); err == nil {
+				t.Errorf("Load after Delete succeeded")
+			}
+			if err := st.Delete(sortedKeys[1]); err == nil {
+				t.Errorf("Delete of a missing key succeeded")
+			}
+			keys, err := st.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if !reflect.DeepEqual(keys, sortedKeys[:1]) {
+				t.Errorf("List = %v, want %v", keys, sortedKeys[:1])
+			}
+		})
+	}
+}
+
+func TestStoreListAndIterateOrder(t *testing.T) {
+	for _, tt := range backends {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.open(t, t.TempDir())
+			// Save out of order, so the store has to sort
+			for _, i := range []int{3, 0, 5, 1, 4, 2} {
+				key := sortedKeys[i]
+				if err := st.Save(key, recording("GET", "/"+key, 200)); err != nil {
+					t.Fatalf("Save(%s): %v", key, err)
+				}
+			}
+
+			keys, err := st.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if !reflect.DeepEqual(keys, sortedKeys) {
+				t.Errorf("List = %v, want %v", keys, sortedKeys)
+			}
+
+			var visited []string
+			err = st.Iterate(func(key string, cached *CachedResponse, err error) error {
+				if err != nil {
+					t.Errorf("Iterate passed an error for %s: %v", key, err)
+					return nil
+				}
+				if cached.Path != "/"+key {
+					t.Errorf("Iterate passed %s for key %s", cached.Path, key)
+				}
+				visited = append(visited, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Iterate: %v", err)
+			}
+			if !reflect.DeepEqual(visited, sortedKeys) {
+				t.Errorf("Iterate visited %v, want %v", visited, sortedKeys)
+			}
+		})
+	}
+}
+
+func TestStoreIterateStops(t *testing.T) {
+	stop := errors.New("stop")
+	for _, tt := range backends {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.open(t, t.TempDir())
+			for _, key := range sortedKeys {
+				if err := st.Save(key, recording("GET", "/"+key, 200)); err != nil {
+					t.Fatalf("Save: %v", err)
+				}
+			}
+
+			visited := 0
+			err := st.Iterate(func(key string, cached *CachedResponse, err error) error {
+				visited++
+				if visited == 2 {
+					return stop
+				}
+				return nil
+			})
+			if !errors.Is(err, stop) {
+				t.Errorf("Iterate = %v, want the error fn returned", err)
+			}
+			if visited != 2 {
+				t.Errorf("Iterate visited %d recordings after fn failed, want 2", visited)
+			}
+		})
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	for _, tt := range backends {
+		if !tt.reopen {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			st := tt.open(t, dir)
+			want := recording("PUT", "/api/items/7", 200)
+			want.Request.Body = RequestBody{0xff, 0x00, 0x7f}
+			key := sortedKeys[4]
+			if err := st.Save(key, want); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := Close(st); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			got, err := tt.open(t, dir).Load(key)
+			if err != nil {
+				t.Fatalf("Load after reopening: %v", err)
+			}
+			assertRecording(t, got, want)
+		})
+	}
+}
+
+// assertRecording compares the parts of a recording the store must keep
+func assertRecording(t *testing.T, got, want *CachedResponse) {
+	t.Helper()
+	if got.Method != want.Method || got.Path != want.Path || got.StatusCode != want.StatusCode {
+		t.Errorf("got %s %s %d, want %s %s %d", got.Method, got.Path, got.StatusCode, want.Method, want.Path, want.StatusCode)
+	}
+	if !bytes.Equal(got.Body, want.Body) {
+		t.Errorf("response body = %q, want %q", got.Body, want.Body)
+	}
+	if !reflect.DeepEqual(got.Headers, want.Headers) {
+		t.Errorf("response headers = %v, want %v", got.Headers, want.Headers)
+	}
+	if got.Request == nil {
+		t.Fatalf("request was not stored")
+	}
+	if !bytes.Equal(got.Request.Body, want.Request.Body) {
+		t.Errorf("request body = %q, want %q byte for byte", got.Request.Body, want.Request.Body)
+	}
+	if !got.Request.Timestamp.Equal(want.Request.Timestamp) {
+		t.Errorf("request timestamp = %v, want %v", got.Request.Timestamp, want.Request.Timestamp)
+	}
+}
+
+func TestBoltReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recordings.db")
+	if _, err := OpenBoltReadOnly(path); err == nil {
+		t.Fatalf("OpenBoltReadOnly of a missing database succeeded")
+	}
+
+	st := mustOpen(t, BackendBolt, path)
+	want := recording("GET", "/api/items", 200)
+	if err := st.Save(sortedKeys[0], want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Close(st); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Readers share the database
+	readers := []Store{mustOpenReadOnly(t, path), mustOpenReadOnly(t, path)}
+	for _, r := range readers {
+		got, err := r.Load(sortedKeys[0])
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		assertRecording(t, got, want)
+	}
+	if err := readers[0].Save(sortedKeys[1], want); err == nil {
+		t.Errorf("Save to a read-only database succeeded")
+	}
+	if err := readers[0].Delete(sortedKeys[0]); err == nil {
+		t.Errorf("Delete from a read-only database succeeded")
+	}
+}
+
+func mustOpenReadOnly(t *testing.T, path string) Store {
+	t.Helper()
+	st, err := OpenReadOnly(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	t.Cleanup(func() { Close(st) })
+	return st
+}
+
+func TestBoltSearchPathPrefix(t *testing.T) {
+	st := mustOpen(t, BackendBolt, filepath.Join(t.TempDir(), "recordings.db"))
+	paths := map[string]string{
+		sortedKeys[0]: "/api",
+		sortedKeys[1]: "/api/v1",
+		sortedKeys[2]: "/api/v1/users",
+		sortedKeys[3]: "/api/v10",
+		sortedKeys[4]: "/api/v1x/users",
+	}
+	for key, path := range paths {
+		if err := st.Save(key, recording("GET", path, 200)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"/api/v1", []string{"/api/v1", "/api/v1/users"}},
+		{"/api/v1/", []string{"/api/v1", "/api/v1/users"}},
+		{"/api", []string{"/api", "/api/v1", "/api/v1/users", "/api/v10", "/api/v1x/users"}},
+		{"/ap", nil},
+	}
+	for _, tt := range tests {
+		found, err := st.(Searcher).Search(Query{PathPrefix: tt.prefix})
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		var got []string
+		for _, s := range found {
+			got = append(got, s.Path)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/jsonpath"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+func init() {
+	Register("backend-url", newBackendURL)
+	Register("replace", newReplace)
+	Register("json-patch", newJSONPatch)
+	Register("refresh-dates", newRefreshDates)
+	Register("headers", newHeaders)
+}
+
+// newBackendURL replaces absolute backend URLs in headers and body with the
+// proxy URL, so links and redirects lead back through the proxy
+//
+//	from  URLs to replace (default: the backend URL over http and https)
+//	to    replacement (default: the URL the request reached the proxy at)
+func newBackendURL(opts Options) (Transformer, error) {
+	var o struct {
+		From []string `json:"from"`
+		To   string   `json:"to"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+
+	return Func(func(ctx *Context, resp *storage.Response) error {
+		from := o.From
+		if len(from) == 0 {
+			from = schemeVariants(ctx.BackendURL)
+		}
+		to := o.To
+		if to == "" {
+			to = ctx.ProxyURL
+		}
+
+		pairs := make([]string, 0, 2*len(from))
+		for _, f := range from {
+			pairs = append(pairs, strings.TrimSuffix(f, "/"), strings.TrimSuffix(to, "/"))
+		}
+		replacer := strings.NewReplacer(pairs...)
+
+		for _, values := range resp.Headers {
+			for i, v := range values {
+				values[i] = replacer.Replace(v)
+			}
+		}
+		return EditText(resp, func(b []byte) []byte {
+			return []byte(replacer.Replace(string(b)))
+		})
+	}), nil
+}
+
+// schemeVariants returns backend as an http and an https URL without a trailing slash
+func schemeVariants(backend string) []string {
+	u, err := url.Parse(backend)
+	if err != nil || u.Host == "" {
+		return []string{backend}
+	}
+	base := u.Host + strings.TrimSuffix(u.Path, "/")
+	return []string{"https://" + base, "http://" + base}
+}
+
+// newReplace replaces text in the body, and optionally in header values
+//
+//	find     text or regular expression to look for
+//	replace  replacement; with regex, $1 and ${name} refer to groups
+//	regex    treat find as a regular expression
+//	headers  also replace in header values
+func newReplace(opts Options) (Transformer, error) {
+	var o struct {
+		Find    string `json:"find"`
+		Replace string `json:"replace"`
+		Regex   bool   `json:"regex"`
+		Headers bool   `json:"headers"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	if o.Find == "" {
+		return nil, fmt.Errorf("find is required")
+	}
+
+	replace := func(b []byte) []byte {
+		return bytes.ReplaceAll(b, []byte(o.Find), []byte(o.Replace))
+	}
+	if o.Regex {
+		re, err := regexp.Compile(o.Find)
+		if err != nil {
+			return nil, fmt.Errorf("invalid find expression: %w", err)
+		}
+		replace = func(b []byte) []byte {
+			return re.ReplaceAll(b, []byte(o.Replace))
+		}
+	}
+
+	return Func(func(ctx *Context, resp *storage.Response) error {
+		if o.Headers {
+			for _, values := range resp.Headers {
+				for i, v := range values {
+					values[i] = string(replace([]byte(v)))
+				}
+			}
+		}
+		return EditText(resp, replace)
+	}), nil
+}
+
+// newJSONPatch sets and deletes fields of JSON bodies
+//
+//	set     map of JSONPath expression to the value stored there
+//	delete  JSONPath expressions of the fields to remove
+func newJSONPatch(opts Options) (Transformer, error) {
+	var o struct {
+		Set    map[string]interface{} `json:"set"`
+		Delete []string               `json:"delete"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	if len(o.Set) == 0 && len(o.Delete) == 0 {
+		return nil, fmt.Errorf("set or delete is required")
+	}
+
+	type assignment struct {
+		path  *jsonpath.Path
+		value interface{}
+	}
+	var sets []assignment
+	for expr, value := range o.Set {
+		path, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, assignment{path: path, value: value})
+	}
+	// Apply assignments in a stable order in case their paths overlap
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].path.String() < sets[j].path.String()
+	})
+	deletes, err := parsePaths(o.Delete)
+	if err != nil {
+		return nil, err
+	}
+
+	return Func(func(ctx *Context, resp *storage.Response) error {
+		return EditJSON(resp, func(doc interface{}) interface{} {
+			for _, path := range deletes {
+				doc = path.Delete(doc)
+			}
+			for _, s := range sets {
+				doc = s.path.Set(doc, s.value)
+			}
+			return doc
+		})
+	}), nil
+}
+
+// newRefreshDates moves dates in JSON bodies and headers to the time of the
+// replay, keeping each value's format (RFC 3339, HTTP dates, plain dates,
+// and Unix timestamps in seconds or milliseconds)
+//
+//	paths    JSONPath expressions of the date fields
+//	headers  date headers to refresh (default: Date, if no paths are given)
+//	shift    move every date by the time since the recording was made instead
+//	         of setting it to now, so dates keep their distance to each other
+func newRefreshDates(opts Options) (Transformer, error) {
+	var o struct {
+		Paths   []string `json:"paths"`
+		Headers []string `json:"headers"`
+		Shift   bool     `json:"shift"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	paths, err := parsePaths(o.Paths)
+	if err != nil {
+		return nil, err
+	}
+	headers := o.Headers
+	if len(paths) == 0 && len(headers) == 0 {
+		headers = []string{"Date"}
+	}
+
+	return Func(func(ctx *Context, resp *storage.Response) error {
+		refresh := func(t time.Time) time.Time {
+			if recorded := ctx.RecordedAt(); o.Shift && !recorded.IsZero() {
+				return t.Add(ctx.Now.Sub(recorded))
+			}
+			return ctx.Now
+		}
+
+		header := http.Header(resp.Headers)
+		for _, name := range headers {
+			if value := header.Get(name); value != "" {
+				if refreshed, ok := refreshDate(value, refresh); ok {
+					header.Set(name, refreshed.(string))
+				}
+			}
+		}
+
+		if len(paths) == 0 {
+			return nil
+		}
+		return EditJSON(resp, func(doc interface{}) interface{} {
+			for _, path := range paths {
+				doc = path.Replace(doc, func(v interface{}) interface{} {
+					if refreshed, ok := refreshDate(v, refresh); ok {
+						return refreshed
+					}
+					return v
+				})
+			}
+			return doc
+		})
+	}), nil
+}
+
+// dateLayouts are the string date formats refresh-dates recognizes
+var dateLayouts = []string{
+	time.RFC3339,
+	http.TimeFormat,
+	time.RFC1123Z,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// refreshDate passes the date held by value through refresh and returns it in
+// the original format. Values that aren't dates are reported with ok == false
+func refreshDate(value interface{}, refresh func(time.Time) time.Time) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range dateLayouts {
+			t, err := time.Parse(layout, v)
+			if err != nil {
+				continue
+			}
+			// Keep the number of fractional digits of RFC 3339 timestamps
+			if layout == time.RFC3339 {
+				layout = rfc3339WithFraction(v)
+			}
+			return refresh(t).In(t.Location()).Format(layout), true
+		}
+
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return value, false
+		}
+		switch {
+		case n >= 1e12: // Milliseconds
+			return json.Number(fmt.Sprint(refresh(time.UnixMilli(n)).UnixMilli())), true
+		case n >= 1e9: // Seconds
+			return json.Number(fmt.Sprint(refresh(time.Unix(n, 0)).Unix())), true
+		}
+	}
+	return value, false
+}
+
+// rfc3339WithFraction returns an RFC 3339 layout with as many fractional
+// second digits as the timestamp s has
+func rfc3339WithFraction(s string) string {
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		return time.RFC3339
+	}
+	digits := 0
+	for _, c := range s[dot+1:] {
+		if c < '0' || c > '9' {
+			break
+		}
+		digits++
+	}
+	return "2006-01-02T15:04:05." + strings.Repeat("0", digits) + "Z07:00"
+}
+
+// newHeaders sets and removes response headers
+//
+//	set     map of header name to value
+//	delete  names of headers to remove
+func newHeaders(opts Options) (Transformer, error) {
+	var o struct {
+		Set    map[string]string `json:"set"`
+		Delete []string          `json:"delete"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	if len(o.Set) == 0 && len(o.Delete) == 0 {
+		return nil, fmt.Errorf("set or delete is required")
+	}
+
+	return Func(func(ctx *Context, resp *storage.Response) error {
+		header := http.Header(resp.Headers)
+		for _, name := range o.Delete {
+			header.Del(name)
+		}
+		for name, value := range o.Set {
+			header.Set(name, value)
+		}
+		return nil
+	}), nil
+}
+
+// parsePaths compiles a list of JSONPath expressions
+func parsePaths(exprs []string) ([]*jsonpath.Path, error) {
+	paths := make([]*jsonpath.Path, 0, len(exprs))
+	for _, expr := range exprs {
+		path, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,236 @@
+package transform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+// Context describes the replay a response is transformed for
+type Context struct {
+	Request    *http.Request           // Request being answered
+	Recording  *storage.CachedResponse // Recording the response comes from
+	BackendURL string                  // Backend the recording was made against
+	ProxyURL   string                  // Base URL clients reach the proxy at, e.g. http://localhost:3000
+	Now        time.Time               // Time of the replay
+}
+
+// RecordedAt returns when the recording was made, or the zero time if unknown
+func (c *Context) RecordedAt() time.Time {
+	if c.Recording == nil || c.Recording.Request == nil {
+		return time.Time{}
+	}
+	return c.Recording.Request.Timestamp
+}
+
+// Transformer rewrites a recorded response before it is replayed
+// resp is a private copy that may be modified in place
+type Transformer interface {
+	Transform(ctx *Context, resp *storage.Response) error
+}
+
+// Func adapts an ordinary function to the Transformer interface
+type Func func(ctx *Context, resp *storage.Response) error
+
+// Transform calls f(ctx, resp)
+func (f Func) Transform(ctx *Context, resp *storage.Response) error {
+	return f(ctx, resp)
+}
+
+// Options are the type-specific settings of a rule, as read from the rules file
+type Options map[string]interface{}
+
+// Decode copies the options into dst, a pointer to a struct with json tags
+// Options dst has no field for are reported as errors
+func (o Options) Decode(dst interface{}) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// Factory builds a transformer from the options of a rule
+type Factory func(opts Options) (Transformer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a transformer type available to rules under name
+// Custom transformers must be registered before the configuration is loaded
+// It panics if name is already registered
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("transform: %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Types returns the names of all registered transformer types, sorted
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds a transformer of the registered type name
+func New(name string, opts Options) (Transformer, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown transform type: %s (must be one of %s)", name, strings.Join(Types(), ", "))
+	}
+	t, err := factory(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// Rule applies a transformer to the responses of requests matching a pattern
+type Rule struct {
+	Name        string // Transform type, used in log lines
+	Pattern     *route.Pattern
+	Transformer Transformer
+}
+
+// Pipeline applies every rule matching a request, in order
+type Pipeline struct {
+	Rules []Rule
+}
+
+// Apply returns a transformed copy of resp along with the names of the rules
+// that were applied. resp itself is returned if no rule matches
+func (p *Pipeline) Apply(ctx *Context, resp *storage.Response) (*storage.Response, []string, error) {
+	var matched []Rule
+	for _, rule := range p.Rules {
+		if rule.Pattern.MatchRequest(ctx.Request) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 {
+		return resp, nil, nil
+	}
+
+	out := editableCopy(resp)
+
+	names := make([]string, 0, len(matched))
+	for _, rule := range matched {
+		if err := rule.Transformer.Transform(ctx, out); err != nil {
+			return resp, nil, fmt.Errorf("%s transform failed: %w", rule.Name, err)
+		}
+		names = append(names, rule.Name)
+	}
+	return out, names, nil
+}
+
+// editableCopy returns a deep copy of resp
+// The body stays as recorded until a transform decodes it with DecodeBody
+func editableCopy(resp *storage.Response) *storage.Response {
+	out := *resp
+	out.Headers = make(map[string][]string, len(resp.Headers))
+	for key, values := range resp.Headers {
+		out.Headers[key] = append([]string(nil), values...)
+	}
+	out.Body = append(storage.ResponseBody(nil), resp.Body...)
+	out.Chunks = append([]storage.Chunk(nil), resp.Chunks...)
+	out.WebSocket = append([]storage.WebSocketMessage(nil), resp.WebSocket...)
+	return &out
+}
+
+// DecodeBody makes the body of resp plain text for transforms that edit it
+// gzip-encoded bodies are decompressed and served without Content-Encoding,
+// so responses whose body is never touched are served compressed as recorded
+func DecodeBody(resp *storage.Response) error {
+	encoding := strings.ToLower(http.Header(resp.Headers).Get("Content-Encoding"))
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip":
+		if len(resp.Chunks) > 0 {
+			return fmt.Errorf("cannot transform a gzip-encoded stream")
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(resp.Body))
+		if err != nil {
+			return fmt.Errorf("failed to decompress body: %w", err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			return fmt.Errorf("failed to decompress body: %w", err)
+		}
+		resp.Body = body
+		http.Header(resp.Headers).Del("Content-Encoding")
+		return nil
+	default:
+		return fmt.Errorf("cannot transform a %s-encoded body", encoding)
+	}
+}
+
+// EditText applies fn to the body and, for streamed responses, to every chunk
+func EditText(resp *storage.Response, fn func([]byte) []byte) error {
+	if err := DecodeBody(resp); err != nil {
+		return err
+	}
+	if len(resp.Body) > 0 {
+		resp.Body = fn(resp.Body)
+	}
+	for i, chunk := range resp.Chunks {
+		resp.Chunks[i] = storage.NewChunk(time.Duration(chunk.Offset), fn(chunk.Bytes()))
+	}
+	return nil
+}
+
+// EditJSON decodes a JSON body, applies fn to the document, and encodes the
+// result as the new body. Bodies that aren't JSON are left alone
+func EditJSON(resp *storage.Response, fn func(doc interface{}) interface{}) error {
+	if err := DecodeBody(resp); err != nil {
+		return err
+	}
+	if len(resp.Body) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(resp.Body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fn(doc)); err != nil {
+		return fmt.Errorf("failed to encode JSON body: %w", err)
+	}
+	resp.Body = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return nil
+}
@@ -0,0 +1,215 @@
+package transform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/chameleon/internal/route"
+	"github.com/yourusername/chameleon/internal/storage"
+)
+
+var (
+	recordedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	replayedAt = recordedAt.Add(24 * time.Hour)
+)
+
+// testContext returns the context of a replay of a recording made at recordedAt
+func testContext() *Context {
+	return &Context{
+		Request:    httptest.NewRequest("GET", "/api/orders/1", nil),
+		Recording:  &storage.CachedResponse{Request: &storage.CachedRequest{Timestamp: recordedAt}},
+		BackendURL: "https://api.example.test/",
+		ProxyURL:   "http://localhost:3000",
+		Now:        replayedAt,
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		headers     map[string][]string
+		body        string
+		wantHeaders map[string][]string
+		wantBody    string
+	}{
+		{
+			name:        "backend-url",
+			opts:        Options{},
+			headers:     map[string][]string{"Location": {"https://api.example.test/orders/2"}},
+			body:        `{"next":"http://api.example.test/orders?page=2"}`,
+			wantHeaders: map[string][]string{"Location": {"http://localhost:3000/orders/2"}},
+			wantBody:    `{"next":"http://localhost:3000/orders?page=2"}`,
+		},
+		{
+			name:        "backend-url",
+			opts:        Options{"from": []interface{}{"https://cdn.example.test"}, "to": "http://localhost:4000"},
+			headers:     map[string][]string{},
+			body:        `https://cdn.example.test/a.png https://api.example.test/b`,
+			wantHeaders: map[string][]string{},
+			wantBody:    `http://localhost:4000/a.png https://api.example.test/b`,
+		},
+		{
+			name:        "replace",
+			opts:        Options{"find": "prod", "replace": "test", "headers": true},
+			headers:     map[string][]string{"X-Env": {"prod"}},
+			body:        "env=prod",
+			wantHeaders: map[string][]string{"X-Env": {"test"}},
+			wantBody:    "env=test",
+		},
+		{
+			name:        "replace",
+			opts:        Options{"find": `id-(\d+)`, "replace": "order-$1", "regex": true},
+			headers:     map[string][]string{"X-Id": {"id-7"}},
+			body:        "id-7, id-8",
+			wantHeaders: map[string][]string{"X-Id": {"id-7"}},
+			wantBody:    "order-7, order-8",
+		},
+		{
+			name:        "json-patch",
+			opts:        Options{"set": map[string]interface{}{"$.status": "paid"}, "delete": []interface{}{"$.secret"}},
+			headers:     map[string][]string{},
+			body:        `{"id":1,"secret":"x","status":"open","total":12.50}`,
+			wantHeaders: map[string][]string{},
+			wantBody:    `{"id":1,"status":"paid","total":12.50}`,
+		},
+		{
+			name:        "json-patch",
+			opts:        Options{"set": map[string]interface{}{"$.status": "paid"}},
+			headers:     map[string][]string{},
+			body:        "not json",
+			wantHeaders: map[string][]string{},
+			wantBody:    "not json",
+		},
+		{
+			name:        "refresh-dates",
+			opts:        Options{},
+			headers:     map[string][]string{"Date": {"Wed, 01 May 2024 10:00:00 GMT"}},
+			body:        `{"createdAt":"2024-05-01T10:00:00Z"}`,
+			wantHeaders: map[string][]string{"Date": {"Thu, 02 May 2024 12:00:00 GMT"}},
+			wantBody:    `{"createdAt":"2024-05-01T10:00:00Z"}`,
+		},
+		{
+			name:        "refresh-dates",
+			opts:        Options{"paths": []interface{}{"$.createdAt", "$.day", "$.ts"}, "shift": true},
+			headers:     map[string][]string{"Date": {"Wed, 01 May 2024 10:00:00 GMT"}},
+			body:        `{"createdAt":"2024-05-01T10:00:00Z","day":"2024-04-30","name":"2024","ts":1714557600}`,
+			wantHeaders: map[string][]string{"Date": {"Wed, 01 May 2024 10:00:00 GMT"}},
+			wantBody:    `{"createdAt":"2024-05-02T10:00:00Z","day":"2024-05-01","name":"2024","ts":1714644000}`,
+		},
+		{
+			name:        "headers",
+			opts:        Options{"set": map[string]interface{}{"cache-control": "no-store"}, "delete": []interface{}{"Set-Cookie"}},
+			headers:     map[string][]string{"Set-Cookie": {"a=1"}, "Cache-Control": {"max-age=60"}},
+			body:        "unchanged",
+			wantHeaders: map[string][]string{"Cache-Control": {"no-store"}},
+			wantBody:    "unchanged",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer, err := New(tt.name, tt.opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			resp := &storage.Response{StatusCode: 200, Headers: tt.headers, Body: storage.ResponseBody(tt.body)}
+			if err := transformer.Transform(testContext(), resp); err != nil {
+				t.Fatalf("Transform: %v", err)
+			}
+			if string(resp.Body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", resp.Body, tt.wantBody)
+			}
+			if !reflect.DeepEqual(resp.Headers, tt.wantHeaders) {
+				t.Errorf("headers = %v, want %v", resp.Headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		error string
+	}{
+		{"rot13", nil, "unknown transform type"},
+		{"replace", Options{}, "find is required"},
+		{"replace", Options{"find": "(", "regex": true}, "invalid find expression"},
+		{"json-patch", Options{}, "set or delete is required"},
+		{"headers", Options{"set": map[string]interface{}{"X": "1"}, "remove": []interface{}{"Y"}}, `unknown field "remove"`},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.name, tt.opts); err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("New(%s, %v) = %v, want an error containing %q", tt.name, tt.opts, err, tt.error)
+		}
+	}
+}
+
+func TestPipelineGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("env=prod"))
+	zw.Close()
+	recorded := &storage.Response{
+		StatusCode: 200,
+		Headers:    map[string][]string{"Content-Encoding": {"gzip"}},
+		Body:       storage.ResponseBody(compressed.Bytes()),
+	}
+
+	rule := func(name string, opts Options) Rule {
+		transformer, err := New(name, opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return Rule{Name: name, Pattern: mustParse(t, "*"), Transformer: transformer}
+	}
+	headers := rule("headers", Options{"set": map[string]interface{}{"X-Replayed": "1"}})
+	replace := rule("replace", Options{"find": "prod", "replace": "test"})
+
+	// Header edits leave the body compressed as recorded
+	out, applied, err := (&Pipeline{Rules: []Rule{headers}}).Apply(testContext(), recorded)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if !bytes.Equal(out.Body, recorded.Body) || out.Headers["Content-Encoding"] == nil {
+		t.Errorf("headers transform changed the body encoding: %v", out.Headers)
+	}
+	if !reflect.DeepEqual(applied, []string{"headers"}) {
+		t.Errorf("applied = %v, want [headers]", applied)
+	}
+
+	// Body edits decompress it
+	out, _, err = (&Pipeline{Rules: []Rule{headers, replace}}).Apply(testContext(), recorded)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if string(out.Body) != "env=test" || out.Headers["Content-Encoding"] != nil {
+		t.Errorf("got body %q with headers %v, want the edited body without Content-Encoding", out.Body, out.Headers)
+	}
+
+	// The recording itself is never modified
+	if !bytes.Equal(recorded.Body, compressed.Bytes()) || len(recorded.Headers) != 1 {
+		t.Errorf("Apply modified the recorded response: %v", recorded.Headers)
+	}
+
+	// No matching rule returns the recorded response
+	skipped := Rule{Name: "replace", Pattern: mustParse(t, "POST /other"), Transformer: replace.Transformer}
+	if out, applied, _ := (&Pipeline{Rules: []Rule{skipped}}).Apply(testContext(), recorded); out != recorded || len(applied) != 0 {
+		t.Errorf("Apply without a matching rule = %p %v, want the recorded response", out, applied)
+	}
+}
+
+// mustParse parses a route pattern
+func mustParse(t *testing.T, expr string) *route.Pattern {
+	t.Helper()
+	pattern, err := route.Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return pattern
+}
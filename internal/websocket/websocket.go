@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptGUID is the fixed key suffix defined by RFC 6455 for the opening handshake
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxPayload bounds the size of a single frame so a broken stream can't exhaust memory
+const maxPayload = 64 << 20
+
+// Opcode identifies the type of a WebSocket frame
+type Opcode byte
+
+const (
+	OpContinuation Opcode = 0x0
+	OpText         Opcode = 0x1
+	OpBinary       Opcode = 0x2
+	OpClose        Opcode = 0x8
+	OpPing         Opcode = 0x9
+	OpPong         Opcode = 0xA
+)
+
+// String returns the lowercase name of the opcode, as stored in recordings
+func (op Opcode) String() string {
+	switch op {
+	case OpContinuation:
+		return "continuation"
+	case OpText:
+		return "text"
+	case OpBinary:
+		return "binary"
+	case OpClose:
+		return "close"
+	case OpPing:
+		return "ping"
+	case OpPong:
+		return "pong"
+	default:
+		return fmt.Sprintf("opcode(%d)", byte(op))
+	}
+}
+
+// ParseOpcode returns the opcode named name
+func ParseOpcode(name string) (Opcode, error) {
+	for _, op := range []Opcode{OpText, OpBinary, OpClose, OpPing, OpPong} {
+		if op.String() == name {
+			return op, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown WebSocket message type: %s", name)
+}
+
+// IsControl reports whether op is a control frame (close, ping, or pong)
+func (op Opcode) IsControl() bool {
+	return op&0x8 != 0
+}
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol
+func IsUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AcceptKey computes the Sec-WebSocket-Accept value answering a Sec-WebSocket-Key
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Message is a complete WebSocket message, reassembled from its frames
+// Control frames are delivered as messages of their own
+type Message struct {
+	Opcode Opcode
+	Data   []byte
+}
+
+// Decoder reassembles messages from a stream of frames that is fed to it in
+// arbitrary pieces, such as the reads and writes on a proxied connection
+type Decoder struct {
+	buf []byte
+
+	// The fragmented message being assembled, if any
+	fragmented bool
+	op         Opcode
+	fragments  []byte
+}
+
+// Feed appends p to the stream and returns the messages it completed
+// An error means the stream is not valid WebSocket framing; the decoder should
+// not be used after that
+func (d *Decoder) Feed(p []byte) ([]Message, error) {
+	d.buf = append(d.buf, p...)
+
+	var msgs []Message
+	for {
+		f, n, err := parseFrame(d.buf)
+		if err != nil {
+			return msgs, err
+		}
+		if n == 0 {
+			break
+		}
+		d.buf = d.buf[n:]
+
+		switch {
+		case f.op.IsControl():
+			msgs = append(msgs, Message{Opcode: f.op, Data: f.payload})
+
+		case f.op == OpContinuation:
+			if !d.fragmented {
+				return msgs, fmt.Errorf("unexpected continuation frame")
+			}
+			d.fragments = append(d.fragments, f.payload...)
+			if f.fin {
+				msgs = append(msgs, Message{Opcode: d.op, Data: d.fragments})
+				d.fragmented = false
+				d.fragments = nil
+			}
+
+		default:
+			if d.fragmented {
+				return msgs, fmt.Errorf("new message started before the previous one finished")
+			}
+			if f.fin {
+				msgs = append(msgs, Message{Opcode: f.op, Data: f.payload})
+			} else {
+				d.fragmented = true
+				d.op = f.op
+				d.fragments = f.payload
+			}
+		}
+	}
+
+	if len(d.buf) == 0 {
+		d.buf = nil
+	}
+	return msgs, nil
+}
+
+// frame is a single decoded WebSocket frame with its payload unmasked
+type frame struct {
+	fin     bool
+	op      Opcode
+	payload []byte
+}
+
+// parseFrame decodes the frame at the start of b
+// Returns n == 0 if b doesn't hold a complete frame yet
+func parseFrame(b []byte) (f frame, n int, err error) {
+	if len(b) < 2 {
+		return frame{}, 0, nil
+	}
+	if b[0]&0x70 != 0 {
+		return frame{}, 0, fmt.Errorf("reserved frame bits set (compressed frames are not supported)")
+	}
+	f.fin = b[0]&0x80 != 0
+	f.op = Opcode(b[0] & 0x0f)
+	masked := b[1]&0x80 != 0
+
+	length := uint64(b[1] & 0x7f)
+	pos := 2
+	switch length {
+	case 126:
+		if len(b) < 4 {
+			return frame{}, 0, nil
+		}
+		length = uint64(binary.BigEndian.Uint16(b[2:4]))
+		pos = 4
+	case 127:
+		if len(b) < 10 {
+			return frame{}, 0, nil
+		}
+		length = binary.BigEndian.Uint64(b[2:10])
+		pos = 10
+	}
+	if length > maxPayload {
+		return frame{}, 0, fmt.Errorf("frame of %d bytes exceeds the %d byte limit", length, maxPayload)
+	}
+
+	var key []byte
+	if masked {
+		if len(b) < pos+4 {
+			return frame{}, 0, nil
+		}
+		key = b[pos : pos+4]
+		pos += 4
+	}
+	if uint64(len(b)-pos) < length {
+		return frame{}, 0, nil
+	}
+
+	end := pos + int(length)
+	f.payload = append([]byte(nil), b[pos:end]...)
+	if masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+	return f, end, nil
+}
+
+// WriteMessage writes data as a single unmasked frame, as sent by a server
+func WriteMessage(w io.Writer, op Opcode, data []byte) error {
+	header := []byte{0x80 | byte(op), 0}
+	switch n := len(data); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if _, err := w.Write(append(header, data...)); err != nil {
+		return fmt.Errorf("failed to write WebSocket %s frame: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// encodeFrame builds a single frame, masked with mask if it is set
+func encodeFrame(fin bool, op Opcode, payload []byte, mask []byte) []byte {
+	b := []byte{byte(op), 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xffff:
+		b[1] = 126
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b[1] = 127
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	if mask == nil {
+		return append(b, payload...)
+	}
+	b[1] |= 0x80
+	b = append(b, mask...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+// written returns the bytes WriteMessage sends for a message
+func written(t *testing.T, op Opcode, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, op, data); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecoder(t *testing.T) {
+	mask := []byte{0x37, 0xfa, 0x21, 0x3d}
+	long := bytes.Repeat([]byte("x"), 300)
+	huge := bytes.Repeat([]byte{0xab}, 70000)
+
+	tests := []struct {
+		name   string
+		stream []byte
+		want   []Message
+	}{
+		{
+			name:   "server text",
+			stream: written(t, OpText, []byte("hello")),
+			want:   []Message{{Opcode: OpText, Data: []byte("hello")}},
+		},
+		{
+			name:   "masked client text",
+			stream: encodeFrame(true, OpText, []byte("hello"), mask),
+			want:   []Message{{Opcode: OpText, Data: []byte("hello")}},
+		},
+		{
+			name:   "16-bit length",
+			stream: written(t, OpBinary, long),
+			want:   []Message{{Opcode: OpBinary, Data: long}},
+		},
+		{
+			name:   "64-bit length",
+			stream: encodeFrame(true, OpBinary, huge, mask),
+			want:   []Message{{Opcode: OpBinary, Data: huge}},
+		},
+		{
+			name: "several frames",
+			stream: concat(
+				written(t, OpText, []byte("one")),
+				written(t, OpPing, nil),
+				written(t, OpClose, []byte{0x03, 0xe8}),
+			),
+			want: []Message{
+				{Opcode: OpText, Data: []byte("one")},
+				{Opcode: OpPing, Data: []byte{}},
+				{Opcode: OpClose, Data: []byte{0x03, 0xe8}},
+			},
+		},
+		{
+			name: "fragmented with a control frame in between",
+			stream: concat(
+				encodeFrame(false, OpText, []byte("hel"), mask),
+				encodeFrame(true, OpPing, []byte("p"), mask),
+				encodeFrame(false, OpContinuation, []byte("lo "), mask),
+				encodeFrame(true, OpContinuation, []byte("world"), mask),
+			),
+			want: []Message{
+				{Opcode: OpPing, Data: []byte("p")},
+				{Opcode: OpText, Data: []byte("hello world")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// All at once
+			var d Decoder
+			got, err := d.Feed(tt.stream)
+			if err != nil {
+				t.Fatalf("Feed: %v", err)
+			}
+			assertMessages(t, got, tt.want)
+
+			// One byte at a time, as a slow connection delivers it
+			var slow Decoder
+			got = nil
+			for i := range tt.stream {
+				msgs, err := slow.Feed(tt.stream[i : i+1])
+				if err != nil {
+					t.Fatalf("Feed of byte %d: %v", i, err)
+				}
+				got = append(got, msgs...)
+			}
+			assertMessages(t, got, tt.want)
+		})
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+		err    string
+	}{
+		{
+			name:   "continuation without a message",
+			stream: encodeFrame(true, OpContinuation, []byte("x"), nil),
+			err:    "unexpected continuation",
+		},
+		{
+			name: "message inside a fragmented message",
+			stream: concat(
+				encodeFrame(false, OpText, []byte("a"), nil),
+				encodeFrame(true, OpText, []byte("b"), nil),
+			),
+			err: "before the previous one finished",
+		},
+		{
+			name:   "compressed frame",
+			stream: []byte{0x80 | 0x40 | byte(OpText), 0},
+			err:    "reserved frame bits",
+		},
+		{
+			name:   "oversized frame",
+			stream: binary.BigEndian.AppendUint64([]byte{0x80 | byte(OpBinary), 127}, maxPayload+1),
+			err:    "exceeds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decoder
+			_, err := d.Feed(tt.stream)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Feed = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecoderIncomplete(t *testing.T) {
+	frame := encodeFrame(true, OpText, []byte("partial"), []byte{1, 2, 3, 4})
+	var d Decoder
+	msgs, err := d.Feed(frame[:len(frame)-1])
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("Feed of an incomplete frame = %v, %v, want no messages", msgs, err)
+	}
+	msgs, err = d.Feed(frame[len(frame)-1:])
+	if err != nil {
+		t.Fatalf("Feed: %v", err)
+	}
+	assertMessages(t, msgs, []Message{{Opcode: OpText, Data: []byte("partial")}})
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func assertMessages(t *testing.T, got, want []Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Opcode != want[i].Opcode || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Errorf("message %d = %s %q, want %s %q", i, got[i].Opcode, shorten(got[i].Data), want[i].Opcode, shorten(want[i].Data))
+		}
+	}
+}
+
+// shorten keeps failure messages readable for large payloads
+func shorten(b []byte) []byte {
+	if len(b) > 32 {
+		return b[:32]
+	}
+	return b
+}